@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	breachInput    = ""
+	breachOutput   = ""
+	breachPrefix   = ""
+	breachMinCount = 1
+)
+
+var breachIndexCmd = cobra.Command{
+	Use:   "breach-index",
+	Short: "Build the breached password index",
+	Long: "Build the index for PASSWORD_BREACH_INDEX from a Pwned Passwords SHA-1 file " +
+		"with one HASH:COUNT per line",
+	Run: buildBreachIndex,
+}
+
+func init() {
+	breachIndexCmd.Flags().StringVarP(&breachInput, "input", "i", "", "the Pwned Passwords file to read, stdin if empty")
+	breachIndexCmd.Flags().StringVarP(&breachOutput, "output", "o", "breached-passwords.idx", "the index file to write")
+	breachIndexCmd.Flags().StringVar(&breachPrefix, "prefix", "", "the hash prefix of a range file containing only suffixes")
+	breachIndexCmd.Flags().IntVar(&breachMinCount, "min-count", 1, "skip hashes seen fewer times in breaches")
+}
+
+func buildBreachIndex(cmd *cobra.Command, args []string) {
+	in := os.Stdin
+	if breachInput != "" {
+		f, err := os.Open(breachInput)
+		if err != nil {
+			utils.Fatal("Failed to open input", zap.Error(err))
+		}
+		defer f.Close()
+		in = f
+	}
+
+	out, err := os.Create(breachOutput)
+	if err != nil {
+		utils.Fatal("Failed to create index", zap.Error(err))
+	}
+
+	w := bufio.NewWriter(out)
+	n, err := utils.BuildBreachIndex(bufio.NewReader(in), w, breachPrefix, breachMinCount)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		utils.Fatal("Failed to build index", zap.Error(err))
+	}
+
+	fmt.Printf("Wrote %d hashes to %s\n", n, breachOutput)
+}
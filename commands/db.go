@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var dbCmd = cobra.Command{
+	Use:   "db",
+	Short: "Manage the database",
+}
+
+var dbInitCmd = cobra.Command{
+	Use:   "init",
+	Short: "Create the app database and schema",
+	Long: "Create the app database and schema if they do not exist yet. This needs the CREATEDB " +
+		"privilege and is meant for development, serve expects them to exist.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWithConfig(cmd, dbInit)
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(&dbInitCmd)
+}
+
+func dbInit(c *conf.Configuration) {
+	if err := storage.Init(context.Background(), &c.DB, utils.Logger()); err != nil {
+		utils.Fatal("Failed to initialize the database", zap.Error(err))
+	}
+	fmt.Printf("database %s with schema %s is ready\n", c.DB.AppDatabase, c.DB.AppDatabaseSchema)
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var healthcheckService = ""
+
+var healthcheckCmd = cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check the health of the running server",
+	Long:  "Query the gRPC health service of the server on the local health port and exit non-zero unless it is serving",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWithConfig(cmd, healthcheck)
+	},
+}
+
+func init() {
+	healthcheckCmd.Flags().StringVar(&healthcheckService, "service", "", "the service to check, the whole server if empty")
+}
+
+// healthProbeAddress is the loopback address serving the health service without TLS
+func healthProbeAddress(c *conf.Configuration) string {
+	return fmt.Sprintf("127.0.0.1:%s", utils.Ternary(c.API.HealthPort != "", c.API.HealthPort, "9091"))
+}
+
+// probeHealth returns the serving status of the service, the whole server if empty, on
+// the plaintext probe listener at address
+func probeHealth(ctx context.Context, address string, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	// the probe listener is plaintext and only reachable from the same host
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, fmt.Errorf("unable to connect: %w", err)
+	}
+	defer conn.Close()
+
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, fmt.Errorf("health check failed: %w", err)
+	}
+	return res.Status, nil
+}
+
+func healthcheck(c *conf.Configuration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := probeHealth(ctx, healthProbeAddress(c), healthcheckService)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(status)
+	if status != healthpb.HealthCheckResponse_SERVING {
+		os.Exit(1)
+	}
+}
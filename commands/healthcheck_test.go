@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthProbeAddress(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:9091", healthProbeAddress(&conf.Configuration{}))
+
+	c := &conf.Configuration{}
+	c.API.HealthPort = "8081"
+	assert.Equal(t, "127.0.0.1:8081", healthProbeAddress(c))
+}
+
+func TestProbeHealth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	probeServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(probeServer, healthServer)
+	go probeServer.Serve(lis)
+	defer probeServer.Stop()
+
+	status, err := probeHealth(ctx, lis.Addr().String(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status)
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	status, err = probeHealth(ctx, lis.Addr().String(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status)
+
+	_, err = probeHealth(ctx, lis.Addr().String(), "unknown.Service")
+	assert.NotNil(t, err)
+
+	// nothing listens once the server stopped
+	probeServer.Stop()
+	unreachable, cancelUnreachable := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancelUnreachable()
+	_, err = probeHealth(unreachable, lis.Addr().String(), "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var migrationsDirs = []string{storage.MigrationsDir, storage.SQLiteMigrationsDir}
+
+var migrateCmd = cobra.Command{
+	Use:   "migrate",
+	Short: "Manage the database schema",
+	Long:  "Apply, revert and create the versioned migrations of the database schema",
+}
+
+var migrateUpCmd = cobra.Command{
+	Use:   "up [steps]",
+	Short: "Apply pending migrations",
+	Long:  "Apply the given number of pending migrations, all of them by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		steps := migrationSteps(args, 0)
+		runWithConfig(cmd, func(c *conf.Configuration) { migrateUp(c, steps) })
+	},
+}
+
+var migrateDownCmd = cobra.Command{
+	Use:   "down [steps]",
+	Short: "Revert applied migrations",
+	Long:  "Revert the given number of applied migrations, newest first, one by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		steps := migrationSteps(args, 1)
+		runWithConfig(cmd, func(c *conf.Configuration) { migrateDown(c, steps) })
+	},
+}
+
+var migrateStatusCmd = cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they are applied",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWithConfig(cmd, migrateStatus)
+	},
+}
+
+var migrateCreateCmd = cobra.Command{
+	Use:   "create NAME",
+	Short: "Create empty up and down files for a new migration",
+	Long:  "Create empty up and down files for the next version of every driver, they are embedded into the binary once built",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, err := storage.CreateMigration(migrationsDirs, args[0])
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		if err != nil {
+			fmt.Println("Unable to create migration:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateCreateCmd.Flags().StringSliceVar(&migrationsDirs, "dir", migrationsDirs, "the directories of the migration files, one per driver")
+	migrateCmd.AddCommand(&migrateUpCmd, &migrateDownCmd, &migrateStatusCmd, &migrateCreateCmd)
+}
+
+func migrationSteps(args []string, steps int) int {
+	if len(args) == 0 {
+		return steps
+	}
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 {
+		fmt.Println("steps must be a positive number")
+		os.Exit(1)
+	}
+	return steps
+}
+
+func newMigrator(c *conf.Configuration) *storage.Migrator {
+	h, err := storage.Dial(context.Background(), &c.DB, utils.Logger())
+	if err != nil {
+		utils.Fatal("Failed to connect to the database", zap.Error(err))
+	}
+	migrator, err := storage.NewMigrator(h.DB)
+	if err != nil {
+		utils.Fatal("Unable to load migrations", zap.Error(err))
+	}
+	return migrator
+}
+
+func migrateUp(c *conf.Configuration, steps int) {
+	done, err := newMigrator(c).Up(context.Background(), steps)
+	for _, m := range done {
+		fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		utils.Fatal("Migration failed", zap.Error(err))
+	}
+	fmt.Printf("%d migrations applied\n", len(done))
+}
+
+func migrateDown(c *conf.Configuration, steps int) {
+	done, err := newMigrator(c).Down(context.Background(), steps)
+	for _, m := range done {
+		fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		utils.Fatal("Migration failed", zap.Error(err))
+	}
+	fmt.Printf("%d migrations reverted\n", len(done))
+}
+
+func migrateStatus(c *conf.Configuration) {
+	status, err := newMigrator(c).Status(context.Background())
+	if err != nil {
+		utils.Fatal("Unable to read the schema version", zap.Error(err))
+	}
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d_%s %s\n", s.Version, s.Name, applied)
+	}
+}
+
+// checkSchema applies pending migrations if auto-migrate is enabled and refuses
+// to start otherwise, since the handlers expect the current schema
+func checkSchema(ctx context.Context, c *conf.Configuration, h *storage.GormRepository) {
+	migrator, err := storage.NewMigrator(h.DB)
+	if err != nil {
+		utils.Fatal("Unable to load migrations", zap.Error(err))
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		utils.Fatal("Unable to read the schema version", zap.Error(err))
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	if !c.DB.AutoMigrate {
+		utils.Fatal(fmt.Sprintf("Database schema is %d migrations behind, run the migrate up command or set POSTGRES_AUTO_MIGRATE", len(pending)))
+	}
+
+	done, err := migrator.Up(ctx, 0)
+	for _, m := range done {
+		utils.Info(fmt.Sprintf("Applied migration %04d_%s", m.Version, m.Name))
+	}
+	if err != nil {
+		utils.Fatal("Migration failed", zap.Error(err))
+	}
+}
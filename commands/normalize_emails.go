@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var normalizeDryRun = false
+
+var normalizeEmailsCmd = cobra.Command{
+	Use:   "normalize-emails",
+	Short: "Normalize stored emails and report collisions",
+	Long: "Normalize the stored emails of all users. Accounts whose emails only differ in case " +
+		"or spelling of the domain are reported for a manual merge and left untouched.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runWithConfig(cmd, normalizeEmails)
+	},
+}
+
+func init() {
+	normalizeEmailsCmd.Flags().BoolVar(&normalizeDryRun, "dry-run", false, "only report, do not update any email")
+}
+
+func normalizeEmails(c *conf.Configuration) {
+	h, err := storage.Dial(context.Background(), &c.DB, utils.Logger())
+	if err != nil {
+		utils.Fatal("Failed to connect to the database", zap.Error(err))
+	}
+
+	var users []models.User
+	if result := h.DB.Order("created_at").Find(&users); result.Error != nil {
+		utils.Fatal("Unable to load users", zap.Error(result.Error))
+	}
+
+	accounts := map[string][]models.User{}
+	var normalized []string
+	for _, user := range users {
+		email, err := utils.NormalizeEmail(user.Email)
+		if err != nil {
+			fmt.Printf("invalid email %q of user %s\n", user.Email, user.ID)
+			continue
+		}
+		if _, ok := accounts[email]; !ok {
+			normalized = append(normalized, email)
+		}
+		accounts[email] = append(accounts[email], user)
+	}
+
+	collisions, updated := 0, 0
+	for _, email := range normalized {
+		if len(accounts[email]) > 1 {
+			collisions++
+			fmt.Printf("collision on %s:\n", email)
+			for _, user := range accounts[email] {
+				fmt.Printf("  %s %q created %s confirmed %t\n", user.ID, user.Email, user.CreatedAt.Format("2006-01-02"), user.Confirmed)
+			}
+			continue
+		}
+
+		user := accounts[email][0]
+		if user.Email == email {
+			continue
+		}
+		if !normalizeDryRun {
+			if result := h.DB.Model(&user).Update("email", email); result.Error != nil {
+				utils.Fatal(fmt.Sprintf("Unable to update email of user %s", user.ID), zap.Error(result.Error))
+			}
+		}
+		updated++
+	}
+
+	fmt.Printf("%d emails %s, %d collisions to merge manually\n", updated, utils.Ternary(normalizeDryRun, "to normalize", "normalized"), collisions)
+}
@@ -2,8 +2,9 @@ package commands
 
 import (
 	"github.com/hiltpold/lakelandcup-auth-service/conf"
-	"github.com/sirupsen/logrus"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 var configFile = ""
@@ -19,14 +20,21 @@ var rootCmd = cobra.Command{
 // RootCommand will setup and return the root command
 func RootCommand() *cobra.Command {
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "the config file to use")
-	rootCmd.AddCommand(&serveCmd, &versionCmd)
+	rootCmd.AddCommand(&serveCmd, &versionCmd, &breachIndexCmd, &normalizeEmailsCmd, &healthcheckCmd, &migrateCmd, &dbCmd)
 	return &rootCmd
 }
 
 func runWithConfig(cmd *cobra.Command, fn func(conf *conf.Configuration)) {
 	conf, err := conf.LoadConfig(configFile)
 	if err != nil {
-		logrus.Fatalf("Failed to load configuration: %+v", err)
+		utils.Fatal("Failed to load configuration", zap.Error(err))
 	}
+
+	logger, err := utils.NewLogger(conf.Log.Level, conf.Log.Format)
+	if err != nil {
+		utils.Fatal("Failed to create logger", zap.Error(err))
+	}
+	utils.SetLogger(logger)
+
 	fn(conf)
 }
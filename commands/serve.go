@@ -1,17 +1,32 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/interceptors"
+	"github.com/hiltpold/lakelandcup-auth-service/metrics"
 	api "github.com/hiltpold/lakelandcup-auth-service/service"
 	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
 	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/tracing"
 	"github.com/hiltpold/lakelandcup-auth-service/utils"
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 var serveCmd = cobra.Command{
@@ -23,7 +38,20 @@ var serveCmd = cobra.Command{
 }
 
 func serve(c *conf.Configuration) {
-	h := storage.Dial(&c.DB)
+	// ctx is cancelled on SIGINT or SIGTERM and starts the shutdown, or aborts the startup
+	// while the database is still being connected
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	h, err := storage.Dial(ctx, &c.DB, utils.Logger())
+	if errors.Is(err, context.Canceled) {
+		utils.Info("Shut down while connecting to the database")
+		return
+	}
+	if err != nil {
+		utils.Fatal("Failed to connect to the database", zap.Error(err))
+	}
+	checkSchema(ctx, c, h)
 	jwt := utils.JwtWrapper{
 		TokenKey:            c.API.TokenSecretKey,
 		TokenExpires:        c.API.TokenExpires,
@@ -40,21 +68,170 @@ func serve(c *conf.Configuration) {
 	lis, err := net.Listen("tcp", serviceUri)
 
 	if err != nil {
-		logrus.Fatal("Failed to listen on: ", err)
+		utils.Fatal("Failed to listen on "+serviceUri, zap.Error(err))
 	}
 
-	logrus.Info(fmt.Sprintf("Service [%s] from app [%s] is running on [%s]", c.API.Svc, c.API.App, serviceUri))
+	utils.Info(fmt.Sprintf("Service [%s] from app [%s] is running on [%s]", c.API.Svc, c.API.App, serviceUri))
 
 	s := api.Server{
-		R:   h,
-		Jwt: jwt,
+		R:    h,
+		Jwt:  jwt,
+		Conf: *c,
+		Log:  utils.Logger(),
+	}
+
+	if c.Password.BreachIndex != "" {
+		breached, err := utils.LoadBreachedPasswords(c.Password.BreachIndex)
+		if err != nil {
+			utils.Fatal("Failed to load breach index", zap.Error(err))
+		}
+		utils.Info(fmt.Sprintf("Loaded [%d] breached password hashes", breached.Len()))
+		s.Breached = breached
 	}
 
-	grpcServer := grpc.NewServer()
+	metricsUri := fmt.Sprintf(":%s", utils.Ternary(c.API.MetricsPort != "", c.API.MetricsPort, "9090"))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{Addr: metricsUri, Handler: mux}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			utils.Error("Metrics listener failed", zap.Error(err))
+		}
+	}()
+	utils.Info(fmt.Sprintf("Metrics are served on [%s/metrics]", metricsUri))
+
+	var jobs sync.WaitGroup
+	jobs.Add(3)
+	go func() { defer jobs.Done(); s.RunAccountPurge(ctx, time.Hour) }()
+	go func() { defer jobs.Done(); s.RunAuditPrune(ctx, 24*time.Hour) }()
+	go func() { defer jobs.Done(); s.RunSessionPrune(ctx, time.Hour) }()
+
+	shutdownTracing, err := tracing.Setup(ctx, c.API.Svc, c.Tracing.Endpoint, c.Tracing.Insecure, c.Tracing.SampleRatio)
+	if err != nil {
+		utils.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+
+	var opts []grpc.ServerOption
+	if c.TLS.CertFile != "" {
+		reloader, err := utils.NewCertReloader(c.TLS.CertFile, c.TLS.KeyFile, c.TLS.ClientCAFile)
+		if err != nil {
+			utils.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig(c.TLS.ClientCertOptional))))
+		utils.Info("Serving with TLS", zap.Bool("mutual", c.TLS.ClientCAFile != ""))
+	} else {
+		utils.Warn("No TLS certificate configured, serving plaintext")
+	}
+
+	// the access log wraps the recovery to log panics as Internal errors
+	grpcServer := grpc.NewServer(append(opts,
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			interceptors.UnaryRequestID(),
+			interceptors.UnaryMetrics(),
+			interceptors.UnaryAccessLog(),
+			interceptors.UnaryRecovery(),
+			interceptors.UnaryValidation(),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			interceptors.StreamRequestID(),
+			interceptors.StreamMetrics(),
+			interceptors.StreamAccessLog(),
+			interceptors.StreamRecovery(),
+			interceptors.StreamValidation(),
+		),
+	)...)
 
 	pb.RegisterAuthServiceServer(grpcServer, &s)
 
-	if err := grpcServer.Serve(lis); err != nil {
-		logrus.Fatalln("Failed to serve:", err)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	jobs.Add(1)
+	go func() { defer jobs.Done(); s.RunHealthChecks(ctx, 10*time.Second, healthServer) }()
+
+	// the healthcheck command probes a plaintext listener on the loopback interface, which
+	// needs no client certificate when the service listener requires one
+	probeUri := healthProbeAddress(c)
+	probeLis, err := net.Listen("tcp", probeUri)
+	if err != nil {
+		utils.Fatal("Failed to listen on "+probeUri, zap.Error(err))
+	}
+	probeServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(probeServer, healthServer)
+	go func() {
+		if err := probeServer.Serve(probeLis); err != nil {
+			utils.Error("Health probe listener failed", zap.Error(err))
+		}
+	}()
+	utils.Info(fmt.Sprintf("Health is served on [%s]", probeUri))
+
+	served := make(chan error, 1)
+	go func() { served <- grpcServer.Serve(lis) }()
+
+	select {
+	case err := <-served:
+		utils.Fatal("Failed to serve", zap.Error(err))
+	case <-ctx.Done():
+	}
+
+	timeout := time.Duration(utils.Ternary(c.API.ShutdownTimeout > 0, c.API.ShutdownTimeout, 30)) * time.Second
+	utils.Info(fmt.Sprintf("Shutting down, draining in-flight requests for up to [%s]", timeout))
+	shutdown(&s, grpcServer, healthServer, probeServer, metricsServer, &jobs, timeout)
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		utils.Error("Flushing traces failed", zap.Error(err))
+	}
+
+	utils.Info("Shutdown complete")
+}
+
+// shutdown stops accepting new calls, waits for in-flight calls, background jobs and
+// mails to finish and stops the remaining calls forcefully once the timeout has passed.
+// The database is closed last, after everything that might still use it.
+func shutdown(s *api.Server, grpcServer *grpc.Server, healthServer *health.Server, probeServer *grpc.Server, metricsServer *http.Server, jobs *sync.WaitGroup, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// report NOT_SERVING so load balancers stop routing new calls here
+	healthServer.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		utils.Warn("Shutdown timeout passed, cancelling in-flight requests")
+		grpcServer.Stop()
+	}
+
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(jobsDone)
+	}()
+
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		utils.Warn("Shutdown timeout passed before background jobs finished")
+	}
+
+	if err := s.WaitBackground(ctx); err != nil {
+		utils.Warn("Shutdown timeout passed before pending mails were sent", zap.Error(err))
+	}
+
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		utils.Error("Stopping the metrics listener failed", zap.Error(err))
+	}
+
+	probeServer.Stop()
+
+	if err := s.R.Close(); err != nil {
+		utils.Error("Closing the database failed", zap.Error(err))
 	}
 }
@@ -1 +1,106 @@
 package commands
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	api "github.com/hiltpold/lakelandcup-auth-service/service"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+// shutdownLog records the order in which the steps of a shutdown finished
+type shutdownLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *shutdownLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+}
+
+func (l *shutdownLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string{}, l.steps...)
+}
+
+type closeRecorder struct {
+	storage.Repository
+	log *shutdownLog
+}
+
+func (r closeRecorder) Close() error {
+	r.log.add("database closed")
+	return r.Repository.Close()
+}
+
+// slowMailer takes delay to send a mail
+type slowMailer struct {
+	delay time.Duration
+	log   *shutdownLog
+}
+
+func (m slowMailer) Send(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string) error {
+	time.Sleep(m.delay)
+	m.log.add("mail sent")
+	return nil
+}
+
+func newShutdownServer(t *testing.T, log *shutdownLog, mailDelay time.Duration) *api.Server {
+	s := &api.Server{
+		R:      closeRecorder{Repository: storage.NewMemoryRepository(), log: log},
+		Jwt:    utils.JwtWrapper{TokenKey: "test-secret", ExpirationHours: 1},
+		Mailer: slowMailer{delay: mailDelay, log: log},
+	}
+	// in enumeration safe mode the reset mail is sent in the background
+	s.Conf.API.EnumerationSafe = true
+
+	require.NoError(t, s.R.Users().Create(context.Background(), &models.User{Email: "ada@example.com", Confirmed: true}))
+	res, err := s.ForgotPassword(context.Background(), &pb.ForgotPasswordRequest{Email: "ada@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, int64(http.StatusOK), res.Status)
+
+	return s
+}
+
+func TestShutdownWaitsForBackgroundWork(t *testing.T) {
+	log := &shutdownLog{}
+	s := newShutdownServer(t, log, 100*time.Millisecond)
+
+	var jobs sync.WaitGroup
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		time.Sleep(50 * time.Millisecond)
+		log.add("job done")
+	}()
+
+	shutdown(s, grpc.NewServer(), health.NewServer(), grpc.NewServer(), &http.Server{}, &jobs, time.Second)
+
+	assert.Equal(t, []string{"job done", "mail sent", "database closed"}, log.get())
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	log := &shutdownLog{}
+	s := newShutdownServer(t, log, time.Second)
+
+	// the database is closed once the timeout has passed, even though the mail is still pending
+	start := time.Now()
+	shutdown(s, grpc.NewServer(), health.NewServer(), grpc.NewServer(), &http.Server{}, &sync.WaitGroup{}, 100*time.Millisecond)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, []string{"database closed"}, log.get())
+
+	assert.Nil(t, s.WaitBackground(context.Background()))
+}
@@ -13,21 +13,35 @@ import (
 
 // Api
 type ApiConfiguration struct {
-	App                   string `mapstructure:"APP"`
-	Svc                   string `mapstructure:"SVC"`
-	Env                   string `mapstructure:"ENV"`
-	Host                  string `mapstructure:"HOST"`
-	Port                  string `mapstructure:"PORT"`
-	TokenSecretKey        string `mapstructure:"JWT_TOKEN_SECRET_KEY"`
-	TokenExpires          int64  `mapstructure:"JWT_TOKEN_EXPIRES_H"`
-	AccessTokenSecretKey  string `mapstructure:"JWT_ACCESS_TOKEN_SECRET_KEY"`
-	AccessTokenExpires    int64  `mapstructure:"JWT_ACCESS_TOKEN_EXPIRES_H"`
-	RefreshTokenSecretKey string `mapstructure:"JWT_REFRESH_TOKEN_SECRET_KEY"`
-	RefreshTokenExpires   int64  `mapstructure:"JWT_REFRESH_TOKEN_EXPIRES_H"`
+	App                    string `mapstructure:"APP"`
+	Svc                    string `mapstructure:"SVC"`
+	Env                    string `mapstructure:"ENV"`
+	Host                   string `mapstructure:"HOST"`
+	Port                   string `mapstructure:"PORT"`
+	MetricsPort            string `mapstructure:"METRICS_PORT"`
+	HealthPort             string `mapstructure:"HEALTH_PORT"`
+	ShutdownTimeout        int64  `mapstructure:"SHUTDOWN_TIMEOUT_S"`
+	TokenSecretKey         string `mapstructure:"JWT_TOKEN_SECRET_KEY"`
+	TokenExpires           int64  `mapstructure:"JWT_TOKEN_EXPIRES_H"`
+	AccessTokenSecretKey   string `mapstructure:"JWT_ACCESS_TOKEN_SECRET_KEY"`
+	AccessTokenExpires     int64  `mapstructure:"JWT_ACCESS_TOKEN_EXPIRES_H"`
+	RefreshTokenSecretKey  string `mapstructure:"JWT_REFRESH_TOKEN_SECRET_KEY"`
+	RefreshTokenExpires    int64  `mapstructure:"JWT_REFRESH_TOKEN_EXPIRES_H"`
+	EmailChangeExpires     int64  `mapstructure:"EMAIL_CHANGE_EXPIRES_H"`
+	EmailChangeUndoExpires int64  `mapstructure:"EMAIL_CHANGE_UNDO_EXPIRES_H"`
+	DeletionGracePeriod    int64  `mapstructure:"ACCOUNT_DELETION_GRACE_PERIOD_H"`
+	DeletionMode           string `mapstructure:"ACCOUNT_DELETION_MODE"`
+	AuditRetention         int64  `mapstructure:"AUDIT_RETENTION_D"`
+	EnumerationSafe        bool   `mapstructure:"ENUMERATION_SAFE"`
+	TrustedProxies         string `mapstructure:"TRUSTED_PROXIES"`
+	TrustedProxyIdentities string `mapstructure:"TRUSTED_PROXY_IDENTITIES"`
 }
 
-// PostgresConfiguration holds all the database related configuration.
+// PostgresConfiguration holds all the database related configuration. With the sqlite
+// driver only the path is used, ":memory:" by default.
 type PostgresConfiguration struct {
+	Driver            string `mapstructure:"DB_DRIVER"`
+	SQLitePath        string `mapstructure:"SQLITE_PATH"`
 	Host              string `mapstructure:"POSTGRES_HOST"`
 	Port              string `mapstructure:"POSTGRES_PORT"`
 	User              string `mapstructure:"POSTGRES_USER"`
@@ -35,6 +49,48 @@ type PostgresConfiguration struct {
 	DefaultDatabase   string `mapstructure:"POSTGRES_DEFAULT_DB"`
 	AppDatabase       string `mapstructure:"POSTGRES_APP_DB"`
 	AppDatabaseSchema string `mapstructure:"POSTGRES_APP_DB_SCHEMA"`
+	AutoMigrate       bool   `mapstructure:"POSTGRES_AUTO_MIGRATE"`
+	ConnectTimeout    int64  `mapstructure:"POSTGRES_CONNECT_TIMEOUT_S"`
+}
+
+// PasswordConfiguration holds the password hashing related configuration.
+type PasswordConfiguration struct {
+	HashAlgorithm     string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost        int    `mapstructure:"PASSWORD_BCRYPT_COST"`
+	Argon2Memory      int    `mapstructure:"PASSWORD_ARGON2_MEMORY_KB"`
+	Argon2Time        int    `mapstructure:"PASSWORD_ARGON2_TIME"`
+	Argon2Parallelism int    `mapstructure:"PASSWORD_ARGON2_PARALLELISM"`
+	MinLength         int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	MaxLength         int    `mapstructure:"PASSWORD_MAX_LENGTH"`
+	RequireLower      bool   `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	RequireUpper      bool   `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	RequireDigit      bool   `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol     bool   `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	AllowPersonal     bool   `mapstructure:"PASSWORD_ALLOW_PERSONAL"`
+	BreachIndex       string `mapstructure:"PASSWORD_BREACH_INDEX"`
+	HistorySize       int    `mapstructure:"PASSWORD_HISTORY_SIZE"`
+}
+
+// LogConfiguration holds the logging related configuration.
+type LogConfiguration struct {
+	Level  string `mapstructure:"LOG_LEVEL"`
+	Format string `mapstructure:"LOG_FORMAT"`
+}
+
+// TracingConfiguration holds the OpenTelemetry related configuration.
+type TracingConfiguration struct {
+	Endpoint    string `mapstructure:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Insecure    bool   `mapstructure:"OTEL_EXPORTER_OTLP_INSECURE"`
+	SampleRatio string `mapstructure:"OTEL_TRACES_SAMPLE_RATIO"`
+}
+
+// TLSConfiguration holds the certificates of the gRPC listener. Without a certificate
+// the listener is plaintext, with a client CA bundle callers need a client certificate.
+type TLSConfiguration struct {
+	CertFile           string `mapstructure:"TLS_CERT_FILE"`
+	KeyFile            string `mapstructure:"TLS_KEY_FILE"`
+	ClientCAFile       string `mapstructure:"TLS_CLIENT_CA_FILE"`
+	ClientCertOptional bool   `mapstructure:"TLS_CLIENT_CERT_OPTIONAL"`
 }
 
 // Sendgrid
@@ -44,9 +100,13 @@ type MailConfiguration struct {
 
 // Configuration holds the api configuration
 type Configuration struct {
-	API  ApiConfiguration      `mapstructure:",squash"`
-	DB   PostgresConfiguration `mapstructure:",squash"`
-	Mail MailConfiguration     `mapstructure:",squash"`
+	API      ApiConfiguration      `mapstructure:",squash"`
+	DB       PostgresConfiguration `mapstructure:",squash"`
+	Mail     MailConfiguration     `mapstructure:",squash"`
+	Password PasswordConfiguration `mapstructure:",squash"`
+	Log      LogConfiguration      `mapstructure:",squash"`
+	Tracing  TracingConfiguration  `mapstructure:",squash"`
+	TLS      TLSConfiguration      `mapstructure:",squash"`
 }
 
 // Load the environment set with the environment file
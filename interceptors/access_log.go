@@ -0,0 +1,86 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// userRequest is implemented by the requests of calls made on behalf of a user
+type userRequest interface {
+	GetUserID() string
+}
+
+// statusResponse is implemented by responses carrying an http status in their body
+type statusResponse interface {
+	GetStatus() int64
+}
+
+// UnaryAccessLog writes one log line per call with the method, the resulting code
+// and status, the latency, the peer and the user the call was made for.
+func UnaryAccessLog() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		accessLog(ctx, info.FullMethod, start, req, res, err)
+		return res, err
+	}
+}
+
+// StreamAccessLog logs streaming calls like UnaryAccessLog once the stream is done
+func StreamAccessLog() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		stream := &recordingStream{ServerStream: ss}
+		err := handler(srv, stream)
+		accessLog(ss.Context(), info.FullMethod, start, stream.req, nil, err)
+		return err
+	}
+}
+
+// recordingStream remembers the request received by a server streaming call
+type recordingStream struct {
+	grpc.ServerStream
+	req interface{}
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.req = m
+	}
+	return err
+}
+
+func accessLog(ctx context.Context, method string, start time.Time, req interface{}, res interface{}, err error) {
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("latency", time.Since(start)),
+		zap.String("requestId", RequestID(ctx)),
+	}
+
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		fields = append(fields, zap.String("traceId", span.TraceID().String()))
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	if identity := PeerIdentity(ctx); identity != "" {
+		fields = append(fields, zap.String("peerIdentity", identity))
+	}
+	if r, ok := req.(userRequest); ok && r.GetUserID() != "" {
+		fields = append(fields, zap.String("userId", r.GetUserID()))
+	}
+	if r, ok := res.(statusResponse); ok {
+		fields = append(fields, zap.Int64("status", r.GetStatus()))
+	}
+
+	utils.Info("Access", fields...)
+}
@@ -0,0 +1,35 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryMetrics counts calls by method and code and observes their latency
+func UnaryMetrics() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		observe(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// StreamMetrics counts streaming calls like UnaryMetrics
+func StreamMetrics() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observe(method string, start time.Time, err error) {
+	metrics.GrpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	metrics.GrpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
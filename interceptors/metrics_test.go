@@ -0,0 +1,25 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryMetrics(t *testing.T) {
+	method := "/auth.AuthService/Login"
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.InvalidArgument, "invalid")
+	}
+
+	before := testutil.ToFloat64(metrics.GrpcRequests.WithLabelValues(method, "InvalidArgument"))
+	UnaryMetrics()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.GrpcRequests.WithLabelValues(method, "InvalidArgument")))
+}
@@ -0,0 +1,40 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerCertificate returns the verified client certificate of the calling service,
+// nil if the call was not made over mutual TLS
+func PeerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return info.State.VerifiedChains[0][0]
+}
+
+// PeerIdentity returns the identity of the calling service, the first URI of its client
+// certificate, e.g. a SPIFFE id, or else its common name. It is empty without mutual TLS.
+func PeerIdentity(ctx context.Context) string {
+	cert := PeerCertificate(ctx)
+	if cert == nil {
+		return ""
+	}
+
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+
+	return cert.Subject.CommonName
+}
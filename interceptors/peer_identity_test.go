@@ -0,0 +1,42 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func withPeerCertificate(cert *x509.Certificate) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+}
+
+func TestPeerIdentity(t *testing.T) {
+	assert.Equal(t, "", PeerIdentity(context.Background()))
+	assert.Nil(t, PeerCertificate(context.Background()))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "league-service"}}
+	assert.Equal(t, "league-service", PeerIdentity(withPeerCertificate(cert)))
+
+	spiffe, _ := url.Parse("spiffe://lakelandcup/league-service")
+	cert.URIs = []*url.URL{spiffe}
+	assert.Equal(t, "spiffe://lakelandcup/league-service", PeerIdentity(withPeerCertificate(cert)))
+
+	// a client certificate that was not verified does not identify the caller
+	unverified := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+	assert.Equal(t, "", PeerIdentity(unverified))
+}
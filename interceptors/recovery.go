@@ -0,0 +1,47 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryRecovery turns a panic of the handler into an Internal error instead of
+// crashing the server, the panic is logged with its stack trace.
+func UnaryRecovery() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery recovers from panics of streaming handlers like UnaryRecovery
+func StreamRecovery() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, r interface{}) error {
+	utils.Error("Recovered from panic",
+		zap.String("method", method),
+		zap.String("requestId", RequestID(ctx)),
+		zap.String("panic", fmt.Sprint(r)),
+		zap.Stack("stack"),
+	)
+	return status.Error(codes.Internal, "Internal server error")
+}
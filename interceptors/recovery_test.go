@@ -0,0 +1,37 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryRecovery(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("handler failed")
+	}
+
+	res, err := UnaryRecovery()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/auth.AuthService/GetUsers"}, handler)
+	assert.Nil(t, res)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestUnaryRequestID(t *testing.T) {
+	var id string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		id = RequestID(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDHeader, "abc"))
+	UnaryRequestID()(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, "abc", id)
+
+	UnaryRequestID()(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Len(t, id, 36)
+}
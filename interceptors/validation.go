@@ -0,0 +1,136 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnaryValidation rejects requests violating the rules declared on their fields with
+// InvalidArgument, the violations are attached as BadRequest details.
+func UnaryValidation() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamValidation validates the request of server streaming calls like UnaryValidation
+func StreamValidation() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ss})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(m)
+}
+
+func validate(req interface{}) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	violations := Violations(msg)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("Invalid %s: %s %s", msg.ProtoReflect().Descriptor().Name(), violations[0].Field, violations[0].Description))
+	if detailed, err := st.WithDetails(badRequest); err == nil {
+		st = detailed
+	}
+
+	return st.Err()
+}
+
+// Violations returns the violations of the rules declared on the fields of the message
+func Violations(msg proto.Message) []utils.FieldViolation {
+	var violations []utils.FieldViolation
+
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		rules, ok := proto.GetExtension(fd.Options(), pb.E_Rules).(*pb.FieldRules)
+		if !ok || rules == nil {
+			continue
+		}
+		for _, description := range check(rules, fd, msg.ProtoReflect().Get(fd)) {
+			violations = append(violations, utils.FieldViolation{Field: string(fd.Name()), Description: description})
+		}
+	}
+
+	return violations
+}
+
+func check(rules *pb.FieldRules, fd protoreflect.FieldDescriptor, value protoreflect.Value) []string {
+	var violations []string
+
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		s := value.String()
+		if s == "" {
+			if rules.Required {
+				violations = append(violations, "must not be empty")
+			}
+			return violations
+		}
+		if rules.MaxLen > 0 && utf8.RuneCountInString(s) > int(rules.MaxLen) {
+			violations = append(violations, fmt.Sprintf("must not be longer than %d characters", rules.MaxLen))
+		}
+		if rules.Email {
+			if _, err := utils.NormalizeEmail(s); err != nil {
+				violations = append(violations, "must be a valid email address")
+			}
+		}
+		if rules.Uuid {
+			if _, err := uuid.Parse(s); err != nil {
+				violations = append(violations, "must be a UUID")
+			}
+		}
+		if rules.Timestamp {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				violations = append(violations, "must be an RFC 3339 timestamp")
+			}
+		}
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		n := value.Int()
+		if rules.Gte != nil && n < *rules.Gte {
+			violations = append(violations, fmt.Sprintf("must be at least %d", *rules.Gte))
+		}
+		if rules.Lte != nil && n > *rules.Lte {
+			violations = append(violations, fmt.Sprintf("must be at most %d", *rules.Lte))
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,49 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestViolations(t *testing.T) {
+	assert.Empty(t, Violations(&pb.RegisterRequest{FirstName: "Max", LastName: "Muster", Email: "max.muster@gmail.com", Password: "password"}))
+	assert.Empty(t, Violations(&pb.ListAuditEventsRequest{UserID: "00000000-0000-0000-0000-000000000000"}))
+
+	violations := Violations(&pb.RegisterRequest{LastName: "Muster", Email: "max.muster", Password: "password"})
+	assert.Len(t, violations, 2)
+	assert.Equal(t, "firstName", violations[0].Field)
+	assert.Equal(t, "email", violations[1].Field)
+
+	violations = Violations(&pb.ListAuditEventsRequest{UserID: "no-uuid", Since: "yesterday", Limit: 5000, Offset: -1})
+	assert.Len(t, violations, 4)
+}
+
+func TestUnaryValidation(t *testing.T) {
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return &pb.GetUsersResponse{}, nil
+	}
+
+	_, err := UnaryValidation()(context.Background(), &pb.GetUsersRequest{UserID: "no-uuid"}, &grpc.UnaryServerInfo{}, handler)
+	assert.False(t, called)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	details := status.Convert(err).Details()
+	assert.Len(t, details, 1)
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	assert.True(t, ok)
+	assert.Equal(t, "userID", badRequest.FieldViolations[0].Field)
+	assert.Equal(t, "must be a UUID", badRequest.FieldViolations[0].Description)
+
+	_, err = UnaryValidation()(context.Background(), &pb.GetUsersRequest{UserID: "00000000-0000-0000-0000-000000000000"}, &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(t, err)
+	assert.True(t, called)
+}
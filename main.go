@@ -1,13 +1,13 @@
 package main
 
 import (
-	"github.com/sirupsen/logrus"
-
 	"github.com/hiltpold/lakelandcup-auth-service/commands"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.uber.org/zap"
 )
 
 func main() {
 	if err := commands.RootCommand().Execute(); err != nil {
-		logrus.Fatal(err)
+		utils.Fatal("Command failed", zap.Error(err))
 	}
 }
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lakelandcup_auth"
+
+// Registry holds every metric of the service
+var Registry = prometheus.NewRegistry()
+
+var (
+	GrpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Handled gRPC calls by method and code.",
+	}, []string{"method", "code"})
+
+	GrpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of handled gRPC calls by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Registered accounts.",
+	})
+
+	Activations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "activations_total",
+		Help:      "Activated accounts.",
+	})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by outcome.",
+	}, []string{"outcome"})
+
+	LoginsRefused = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_refused_total",
+		Help:      "Logins with correct credentials refused because of the account state, by reason.",
+	}, []string{"reason"})
+
+	PasswordResets = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "password_resets_total",
+		Help:      "Passwords reset with a forgot password token.",
+	})
+
+	Emails = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_total",
+		Help:      "Emails by template and result, sent or failed.",
+	}, []string{"template", "result"})
+
+	PasswordHashDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_duration_seconds",
+		Help:      "Duration of hashing and verifying passwords by operation.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of database queries by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		GrpcRequests,
+		GrpcDuration,
+		Registrations,
+		Activations,
+		Logins,
+		LoginsRefused,
+		PasswordResets,
+		Emails,
+		PasswordHashDuration,
+		DBQueryDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditRegister              = "register"
+	AuditLogin                 = "login"
+	AuditActivate              = "activate"
+	AuditResendActivationToken = "resend_activation_token"
+	AuditForgotPassword        = "forgot_password"
+	AuditResetPassword         = "reset_password"
+	AuditRefreshToken          = "refresh_token"
+	AuditValidate              = "validate"
+	AuditGetUsers              = "get_users"
+	AuditRequestEmailChange    = "request_email_change"
+	AuditConfirmEmailChange    = "confirm_email_change"
+	AuditUndoEmailChange       = "undo_email_change"
+	AuditGetMe                 = "get_me"
+	AuditUpdateMe              = "update_me"
+	AuditDeleteAccount         = "delete_account"
+	AuditCancelAccountDeletion = "cancel_account_deletion"
+	AuditExportData            = "export_data"
+	AuditListAuditEvents       = "list_audit_events"
+	AuditListMyLogins          = "list_my_logins"
+	AuditListSessions          = "list_sessions"
+	AuditRevokeSession         = "revoke_session"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent records a security relevant action. Events are append-only,
+// they are never updated and only removed once they exceed the retention period.
+type AuditEvent struct {
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey"`
+	ActorID   *uuid.UUID `json:"actorId" gorm:"index"`
+	SubjectID *uuid.UUID `json:"subjectId" gorm:"index"`
+	Type      string     `json:"type" gorm:"type:varchar(64);not null;index"`
+	ClientIP  string     `json:"clientIp" gorm:"type:varchar(64)"`
+	UserAgent string     `json:"userAgent" gorm:"type:varchar(512)"`
+	// Caller is the certificate identity of the service that made the call over mutual TLS
+	Caller    string    `json:"caller" gorm:"type:varchar(255)"`
+	Outcome   string    `json:"outcome" gorm:"type:varchar(16);not null"`
+	Reason    string    `json:"reason" gorm:"type:varchar(255)"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+func (event *AuditEvent) BeforeCreate(db *gorm.DB) error {
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now().Local()
+	return nil
+}
+
+func (event *AuditEvent) BeforeUpdate(db *gorm.DB) error {
+	return errors.New("audit events are append-only")
+}
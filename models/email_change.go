@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailChange tracks a requested change of a user's email address, from the
+// verification of the new address up to the end of the undo window.
+type EmailChange struct {
+	ID            uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserID        uuid.UUID  `json:"userId" gorm:"not null;index"`
+	OldEmail      string     `json:"oldEmail" gorm:"type:varchar(255);not null"`
+	NewEmail      string     `json:"newEmail" gorm:"type:varchar(255);not null"`
+	ExpiresAt     time.Time  `json:"expiresAt" gorm:"not null"`
+	ConfirmedAt   *time.Time `json:"confirmedAt"`
+	UndoExpiresAt *time.Time `json:"undoExpiresAt"`
+	RevertedAt    *time.Time `json:"revertedAt"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (change *EmailChange) BeforeCreate(db *gorm.DB) error {
+	change.ID = uuid.New()
+	change.CreatedAt = time.Now().Local()
+	return nil
+}
+
+func (change *EmailChange) BeforeUpdate(db *gorm.DB) error {
+	change.UpdatedAt = time.Now().Local()
+	return nil
+}
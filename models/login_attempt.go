@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginAttempt records a successful or failed login. UserID is empty if the
+// email did not belong to any user.
+type LoginAttempt struct {
+	ID          uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserID      *uuid.UUID `json:"userId" gorm:"index"`
+	Email       string     `json:"email" gorm:"type:varchar(255)"`
+	Success     bool       `json:"success" gorm:"type:bool;not null"`
+	ClientIP    string     `json:"clientIp" gorm:"type:varchar(64)"`
+	UserAgent   string     `json:"userAgent" gorm:"type:varchar(512)"`
+	Fingerprint string     `json:"fingerprint" gorm:"type:varchar(64);index"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"index"`
+}
+
+func (attempt *LoginAttempt) BeforeCreate(db *gorm.DB) error {
+	attempt.ID = uuid.New()
+	attempt.CreatedAt = time.Now().Local()
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordHistory keeps a previous password hash of a user, so that recently used
+// passwords cannot be chosen again.
+type PasswordHistory struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserID    uuid.UUID `json:"userId" gorm:"not null;index"`
+	Password  string    `json:"-" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (history *PasswordHistory) BeforeCreate(db *gorm.DB) error {
+	history.ID = uuid.New()
+	history.CreatedAt = time.Now().Local()
+	return nil
+}
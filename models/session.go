@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is created on every successful login, the tokens handed out carry its id.
+// Tokens of a revoked or expired session are refused, expired sessions are pruned.
+type Session struct {
+	ID          uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserID      uuid.UUID  `json:"userId" gorm:"not null;index"`
+	Fingerprint string     `json:"fingerprint" gorm:"type:varchar(64)"`
+	Device      string     `json:"device" gorm:"type:varchar(512)"`
+	ClientIP    string     `json:"clientIp" gorm:"type:varchar(64)"`
+	LastUsedAt  time.Time  `json:"lastUsedAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt" gorm:"index"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func (session *Session) BeforeCreate(db *gorm.DB) error {
+	session.ID = uuid.New()
+	session.CreatedAt = time.Now().Local()
+	session.LastUsedAt = session.CreatedAt
+	return nil
+}
@@ -7,16 +7,23 @@ import (
 	"gorm.io/gorm"
 )
 
+const RoleAdmin = "admin"
+
 type User struct {
-	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
-	FirstName string    `json:"firstName" gorm:"type:varchar(255);not null"`
-	LastName  string    `json:"lastName" gorm:"type:varchar(255);not null"`
-	Email     string    `json:"email" gorm:"type:varchar(255);unique;not null"`
-	Role      string    `json:"role" gorm:"type:varchar(255)"`
-	Confirmed bool      `json:"confirmed" gorm:"type:bool;default:false"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          uuid.UUID  `json:"id" gorm:"primaryKey"`
+	FirstName   string     `json:"firstName" gorm:"type:varchar(255);not null"`
+	LastName    string     `json:"lastName" gorm:"type:varchar(255);not null"`
+	Email       string     `json:"email" gorm:"type:varchar(255);unique;not null"`
+	Role        string     `json:"role" gorm:"type:varchar(255)"`
+	Confirmed   bool       `json:"confirmed" gorm:"type:bool;default:false"`
+	Password    string     `json:"password"`
+	DisplayName string     `json:"displayName" gorm:"type:varchar(255)"`
+	AvatarURL   string     `json:"avatarUrl" gorm:"type:varchar(2048)"`
+	TimeZone    string     `json:"timeZone" gorm:"type:varchar(64)"`
+	Language    string     `json:"language" gorm:"type:varchar(35)"`
+	DeleteAfter *time.Time `json:"deleteAfter" gorm:"index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 func (user *User) BeforeCreate(db *gorm.DB) error {
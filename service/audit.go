@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/interceptors"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.uber.org/zap"
+)
+
+const maxAuditEvents = 1000
+
+// statusResponse is implemented by every response of the AuthService
+type statusResponse interface {
+	GetStatus() int64
+	GetError() string
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func (s *Server) auditRetention() time.Duration {
+	return time.Duration(utils.Ternary(s.Conf.API.AuditRetention > 0, s.Conf.API.AuditRetention, 365)) * 24 * time.Hour
+}
+
+// newAuditEvent starts an audit event of the given type for the calling client
+func (s *Server) newAuditEvent(ctx context.Context, eventType string) *models.AuditEvent {
+	ip, userAgent := s.clientInfo(ctx)
+	return &models.AuditEvent{
+		Type:      eventType,
+		ClientIP:  truncate(ip, 64),
+		UserAgent: truncate(userAgent, 512),
+		Caller:    truncate(interceptors.PeerIdentity(ctx), 255),
+	}
+}
+
+// audit stores the event with the outcome given by the status of the response
+func (s *Server) audit(ctx context.Context, event *models.AuditEvent, res statusResponse) {
+	status := res.GetStatus()
+	event.Outcome = utils.Ternary(status > 0 && status < http.StatusBadRequest, models.AuditOutcomeSuccess, models.AuditOutcomeFailure)
+	event.Reason = truncate(res.GetError(), 255)
+
+	if err := s.R.AuditEvents().Create(ctx, event); err != nil {
+		s.log().Error("Writing audit event failed", zap.Error(err))
+	}
+}
+
+// auditUser sets the actor and the subject of the event to the same user
+func auditUser(event *models.AuditEvent, id uuid.UUID) {
+	event.ActorID = &id
+	event.SubjectID = &id
+}
+
+func toPbAuditEvent(e models.AuditEvent) *pb.AuditEvent {
+	event := &pb.AuditEvent{
+		ID:        e.ID.String(),
+		Type:      e.Type,
+		ClientIP:  e.ClientIP,
+		UserAgent: e.UserAgent,
+		Outcome:   e.Outcome,
+		Reason:    e.Reason,
+		Caller:    e.Caller,
+		CreatedAt: e.CreatedAt.Format(time.RFC3339),
+	}
+	if e.ActorID != nil {
+		event.ActorID = e.ActorID.String()
+	}
+	if e.SubjectID != nil {
+		event.SubjectUserID = e.SubjectID.String()
+	}
+	return event
+}
+
+func (s *Server) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (res *pb.ListAuditEventsResponse, err error) {
+	var admin models.User
+	var events []models.AuditEvent
+
+	event := s.newAuditEvent(ctx, models.AuditListAuditEvents)
+	defer func() { s.audit(ctx, event, res) }()
+
+	adminID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.ListAuditEventsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	event.ActorID = &adminID
+
+	if admin, err = s.R.Users().FindByID(ctx, adminID); err != nil || admin.Role != models.RoleAdmin {
+		return &pb.ListAuditEventsResponse{
+			Status: http.StatusForbidden,
+			Error:  "Only admins can list audit events",
+		}, nil
+	}
+
+	var filter storage.AuditFilter
+
+	if req.ActorID != "" {
+		actorID, err := uuid.Parse(req.ActorID)
+		if err != nil {
+			return &pb.ListAuditEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Invalid actor id",
+			}, nil
+		}
+		filter.ActorID = &actorID
+	}
+
+	if req.SubjectUserID != "" {
+		subjectID, err := uuid.Parse(req.SubjectUserID)
+		if err != nil {
+			return &pb.ListAuditEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Invalid subject user id",
+			}, nil
+		}
+		filter.SubjectID = &subjectID
+	}
+
+	filter.Type = req.Type
+	filter.Outcome = req.Outcome
+
+	if req.Since != "" {
+		since, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			return &pb.ListAuditEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Since must be an RFC 3339 timestamp",
+			}, nil
+		}
+		filter.Since = &since
+	}
+
+	if req.Until != "" {
+		until, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			return &pb.ListAuditEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Until must be an RFC 3339 timestamp",
+			}, nil
+		}
+		filter.Until = &until
+	}
+
+	filter.Limit = utils.Ternary(req.Limit > 0 && req.Limit <= maxAuditEvents, int(req.Limit), maxAuditEvents)
+	filter.Offset = int(req.Offset)
+
+	if events, err = s.R.AuditEvents().List(ctx, filter); err != nil {
+		defer s.log().Error(err.Error())
+		return &pb.ListAuditEventsResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Listing audit events failed",
+		}, nil
+	}
+
+	var resEvents []*pb.AuditEvent
+	for _, e := range events {
+		resEvents = append(resEvents, toPbAuditEvent(e))
+	}
+
+	return &pb.ListAuditEventsResponse{
+		Status: http.StatusOK,
+		Events: resEvents,
+	}, nil
+}
+
+// PruneAuditEvents removes the audit events older than the retention period
+// and returns the number of removed events.
+func (s *Server) PruneAuditEvents(ctx context.Context) (int, error) {
+	deleted, err := s.R.AuditEvents().DeleteBefore(ctx, time.Now().Local().Add(-s.auditRetention()))
+	return int(deleted), err
+}
+
+// RunAuditPrune prunes expired audit events every interval until ctx is done.
+func (s *Server) RunAuditPrune(ctx context.Context, interval time.Duration) {
+	s.runEvery(ctx, interval, "Pruning audit events", s.PruneAuditEvents)
+}
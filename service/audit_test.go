@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+	admin := createConfirmedUser(t, s, "grace@example.com")
+
+	s.Login(withClient("203.0.113.7", "user-agent", "curl/8.0"), &pb.LoginRequest{Email: "ada@example.com", Password: "wrong password"})
+	s.Login(withClient("203.0.113.7", "user-agent", "curl/8.0"), &pb.LoginRequest{Email: "ada@example.com", Password: "correct horse battery staple"})
+
+	// only admins may list the events, the refusal is recorded as well
+	res, _ := s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String()})
+	assert.Equal(t, int64(http.StatusForbidden), res.Status)
+	assert.Empty(t, res.Events)
+
+	admin.Role = models.RoleAdmin
+	require.NoError(t, s.R.Users().Update(ctx, &admin, "role"))
+
+	res, _ = s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String(), Type: models.AuditLogin, SubjectUserID: user.ID.String()})
+	require.Equal(t, int64(http.StatusOK), res.Status)
+	require.Len(t, res.Events, 2)
+	assert.Equal(t, "203.0.113.7", res.Events[0].ClientIP)
+	assert.Equal(t, "curl/8.0", res.Events[0].UserAgent)
+
+	res, _ = s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String(), Type: models.AuditLogin, Outcome: models.AuditOutcomeFailure})
+	require.Len(t, res.Events, 1)
+	assert.Equal(t, user.ID.String(), res.Events[0].SubjectUserID)
+
+	res, _ = s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String(), ActorID: admin.ID.String(), Type: models.AuditListAuditEvents, Outcome: models.AuditOutcomeFailure})
+	assert.Len(t, res.Events, 1)
+
+	res, _ = s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String(), Limit: 1, Offset: 1})
+	assert.Len(t, res.Events, 1)
+
+	res, _ = s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String(), Since: time.Now().Add(time.Hour).Format(time.RFC3339)})
+	assert.Equal(t, int64(http.StatusOK), res.Status)
+	assert.Empty(t, res.Events)
+
+	res, _ = s.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{UserID: admin.ID.String(), Until: "yesterday"})
+	assert.Equal(t, int64(http.StatusBadRequest), res.Status)
+	assert.Equal(t, "Until must be an RFC 3339 timestamp", res.Error)
+}
@@ -2,56 +2,128 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/metrics"
 	"github.com/hiltpold/lakelandcup-auth-service/models"
 	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
 	"github.com/hiltpold/lakelandcup-auth-service/storage"
 	"github.com/hiltpold/lakelandcup-auth-service/utils"
-	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
 )
 
 type Server struct {
-	R   storage.Repository
-	Jwt utils.JwtWrapper
+	R    storage.Repository
+	Jwt  utils.JwtWrapper
+	Conf conf.Configuration
+	Log  *zap.Logger
+	// Mailer sends the mails, SendGrid with the key from the environment if nil
+	Mailer utils.Mailer
+	// Breached passwords are refused as new passwords, nil disables the check
+	Breached *utils.BreachedPasswords
+	// pending tracks the mails sent in the background, see WaitBackground
+	pending sync.WaitGroup
 	// #https://github.com/grpc/grpc-go/issues/3794:
 	pb.UnimplementedAuthServiceServer
 }
 
-func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+// log returns the logger of the server, the default logger if none was injected
+func (s *Server) log() *zap.Logger {
+	if s.Log == nil {
+		return utils.Logger()
+	}
+	return s.Log
+}
+
+// mailer returns the mailer of the server, SendGrid if none was injected
+func (s *Server) mailer() utils.Mailer {
+	if s.Mailer == nil {
+		return utils.SendGridMailer{Key: os.Getenv("SENDGRID_KEY")}
+	}
+	return s.Mailer
+}
+
+// sendMail sends the mail rendered from the template with the token and the url of its link
+func (s *Server) sendMail(ctx context.Context, name string, email string, subject string, fileName string, token string, url string) error {
+	return s.mailer().Send(ctx, name, email, subject, fileName, map[string]string{
+		"token": token,
+		"url":   url,
+	})
+}
+
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (res *pb.RegisterResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditRegister)
+	defer func() { s.audit(ctx, event, res) }()
+
 	var user models.User
 
-	if findUser := s.R.DB.Where(&models.User{Email: req.Email}).First(&user); findUser.Error == nil {
+	email, err := utils.NormalizeEmail(req.Email)
+
+	if err != nil {
+		return &pb.RegisterResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	password := utils.NormalizePassword(req.Password)
+
+	if violations := s.checkNewPassword(ctx, "password", password, models.User{Email: email, FirstName: req.FirstName, LastName: req.LastName}); len(violations) > 0 {
+		return &pb.RegisterResponse{
+			Status:     http.StatusBadRequest,
+			Error:      "Password does not meet the requirements",
+			Violations: toPbViolations(violations),
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByEmail(ctx, email); err == nil {
+		event.SubjectID = &user.ID
+
+		if s.enumerationSafe() {
+			// answer like a successful registration and tell the owner of the address instead
+			s.dummyVerify(ctx, password)
+			s.deliver(func() error {
+				return s.mailer().Send(ctx, user.FirstName, user.Email, "Registration Attempt", "register-attempt", map[string]string{})
+			})
+			return &pb.RegisterResponse{
+				Status: http.StatusCreated,
+			}, nil
+		}
+
 		return &pb.RegisterResponse{
 			Status: http.StatusConflict,
 			Error:  "Email already exists",
 		}, nil
 	}
 
-	user.Email = req.Email
+	user.Email = email
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
-	password, err := utils.HashPassword(req.Password)
+	hash, err := s.hashPassword(ctx, password)
 
 	if err != nil {
 		return nil, err
 	}
-	user.Password = password
+	user.Password = hash
 
-	if createUser := s.R.DB.Create(&user); createUser.Error != nil {
+	if errCreate := s.R.Users().Create(ctx, &user); errCreate != nil {
 		return &pb.RegisterResponse{
 			Status: http.StatusForbidden,
 			Error:  "Register new account failed",
 		}, nil
 	}
 
+	auditUser(event, user.ID)
+	metrics.Registrations.Inc()
+
 	accessToken, errToken := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "")
 
 	if errToken != nil {
-		defer logrus.Error(errToken.Error())
+		defer s.log().Error(errToken.Error())
 		return &pb.RegisterResponse{
 			Status: http.StatusBadRequest,
 			Error:  "Generate accessToken failed",
@@ -59,10 +131,12 @@ func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Reg
 	}
 
 	// TODO: parse api response and check for errors
-	_, errSendMail := utils.SendGridMail(user.FirstName, user.Email, "Account Activation", "register", accessToken, os.Getenv("SENDGRID_KEY"))
+	errSendMail := s.deliver(func() error {
+		return s.sendMail(ctx, user.FirstName, user.Email, "Account Activation", "register", accessToken, "")
+	})
 
 	if errSendMail != nil {
-		defer logrus.Error(errSendMail.Error())
+		defer s.log().Error(errSendMail.Error())
 		return &pb.RegisterResponse{
 			Status: http.StatusBadRequest,
 			Error:  "Sending email activation failed",
@@ -74,16 +148,42 @@ func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Reg
 	}, nil
 }
 
-func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (res *pb.LoginResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditLogin)
+	fingerprint := deviceFingerprint(ctx)
+	defer func() {
+		s.recordLogin(ctx, event, req.Email, fingerprint, res)
+		s.audit(ctx, event, res)
+		metrics.Logins.WithLabelValues(event.Outcome).Inc()
+	}()
+
 	var user models.User
-	if result := s.R.DB.Where(&models.User{Email: req.Email}).First(&user); result.Error != nil {
+
+	email, errEmail := utils.NormalizeEmail(req.Email)
+
+	if errEmail != nil {
+		if s.enumerationSafe() {
+			s.dummyVerify(ctx, req.Password)
+		}
 		return &pb.LoginResponse{
 			Status: http.StatusNotFound,
 			Error:  "Incorrect email or password",
 		}, nil
 	}
 
-	match := utils.CheckPasswordHash(req.Password, user.Password)
+	if user, err = s.R.Users().FindByEmail(ctx, email); err != nil {
+		if s.enumerationSafe() {
+			s.dummyVerify(ctx, req.Password)
+		}
+		return &pb.LoginResponse{
+			Status: http.StatusNotFound,
+			Error:  "Incorrect email or password",
+		}, nil
+	}
+
+	event.SubjectID = &user.ID
+
+	match, rehash := s.verifyPassword(ctx, req.Password, user.Password)
 
 	if !match {
 		return &pb.LoginResponse{
@@ -92,7 +192,20 @@ func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResp
 		}, nil
 	}
 
+	// move the stored hash to the current algorithm and parameters while the plain password is at hand
+	if rehash {
+		if rehashed, errHash := s.hashPassword(ctx, utils.NormalizePassword(req.Password)); errHash == nil {
+			user.Password = rehashed
+			if errUpdate := s.R.Users().Update(ctx, &user, "password"); errUpdate != nil {
+				s.log().Error("Rehashing password failed", zap.Error(errUpdate))
+			}
+		}
+	}
+
+	event.ActorID = &user.ID
+
 	if !user.Confirmed {
+		metrics.LoginsRefused.WithLabelValues("unconfirmed").Inc()
 		return &pb.LoginResponse{
 			Status: http.StatusForbidden,
 			Error:  "User not yet Confirmed",
@@ -100,8 +213,30 @@ func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResp
 
 	}
 
-	accessToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "ACCESS_TOKEN")
-	refreshToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "REFRESH_TOKEN")
+	if user.DeleteAfter != nil {
+		metrics.LoginsRefused.WithLabelValues("deletion_scheduled").Inc()
+		return &pb.LoginResponse{
+			Status: http.StatusForbidden,
+			Error:  "Account is scheduled for deletion",
+		}, nil
+	}
+
+	if s.isNewDevice(ctx, user.ID, fingerprint) {
+		s.background(func() { s.notifyNewDevice(ctx, user, event.UserAgent, event.ClientIP) })
+	}
+
+	session, errSession := s.createSession(ctx, user.ID, event, fingerprint)
+
+	if errSession != nil {
+		defer s.log().Error(errSession.Error())
+		return &pb.LoginResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Session could not be created",
+		}, nil
+	}
+
+	accessToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role, SessionId: session.ID}, "ACCESS_TOKEN")
+	refreshToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role, SessionId: session.ID}, "REFRESH_TOKEN")
 
 	return &pb.LoginResponse{
 		Status:       http.StatusOK,
@@ -111,7 +246,10 @@ func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResp
 	}, nil
 }
 
-func (s *Server) Activate(ctx context.Context, req *pb.ActivateRequest) (*pb.ActivateResponse, error) {
+func (s *Server) Activate(ctx context.Context, req *pb.ActivateRequest) (res *pb.ActivateResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditActivate)
+	defer func() { s.audit(ctx, event, res) }()
+
 	var user models.User
 
 	claims, err := s.Jwt.ValidateToken(req.Token, "")
@@ -123,49 +261,74 @@ func (s *Server) Activate(ctx context.Context, req *pb.ActivateRequest) (*pb.Act
 		}, nil
 	}
 
-	if result := s.R.DB.Where(&models.User{Email: claims.Email}).First(&user); result.Error != nil {
+	if user, err = s.R.Users().FindByEmail(ctx, claims.Email); err != nil {
 		return &pb.ActivateResponse{
 			Status: http.StatusNotFound,
 			Error:  "Token does not belong to a user",
 		}, nil
 	}
 
-	if update := s.R.DB.Model(&user).Update("Confirmed", true); update.Error != nil {
+	auditUser(event, user.ID)
+
+	if errUpdate := s.R.Users().SetConfirmed(ctx, &user); errUpdate != nil {
 		return &pb.ActivateResponse{
 			Status: http.StatusNotFound,
 			Error:  "User could not be updated",
 		}, nil
 	}
 
+	metrics.Activations.Inc()
+
 	return &pb.ActivateResponse{
 		Status: http.StatusOK,
 	}, nil
 }
 
-func (s *Server) ResendActivationToken(ctx context.Context, req *pb.ResendActivationTokenRequest) (*pb.ResendActivationTokenResponse, error) {
+func (s *Server) ResendActivationToken(ctx context.Context, req *pb.ResendActivationTokenRequest) (res *pb.ResendActivationTokenResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditResendActivationToken)
+	defer func() { s.audit(ctx, event, res) }()
+
 	var user models.User
 
-	if result := s.R.DB.Where(&models.User{Email: req.Email}).First(&user); result.Error != nil {
+	email, err := utils.NormalizeEmail(req.Email)
+
+	if err != nil {
+		return &pb.ResendActivationTokenResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByEmail(ctx, email); err != nil {
+		if s.enumerationSafe() {
+			return &pb.ResendActivationTokenResponse{
+				Status: http.StatusOK,
+			}, nil
+		}
 		return &pb.ResendActivationTokenResponse{
 			Status: http.StatusNotFound,
 			Error:  "Email was never registered",
 		}, nil
 	}
 
+	event.SubjectID = &user.ID
+
 	accessToken, errToken := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "")
 
 	if errToken != nil {
-		defer logrus.Error(errToken.Error())
+		defer s.log().Error(errToken.Error())
 		return &pb.ResendActivationTokenResponse{
 			Status: http.StatusBadRequest,
 			Error:  "Generate access token failed",
 		}, nil
 	}
 
-	_, errSendMail := utils.SendGridMail(user.FirstName, user.Email, "Account Activation", "register", accessToken, os.Getenv("SENDGRID_KEY"))
+	errSendMail := s.deliver(func() error {
+		return s.sendMail(ctx, user.FirstName, user.Email, "Account Activation", "register", accessToken, "")
+	})
 
 	if errSendMail != nil {
-		defer logrus.Error(errSendMail.Error())
+		defer s.log().Error(errSendMail.Error())
 		return &pb.ResendActivationTokenResponse{
 			Status: http.StatusBadRequest,
 			Error:  "Sending email activation failed",
@@ -177,17 +340,41 @@ func (s *Server) ResendActivationToken(ctx context.Context, req *pb.ResendActiva
 	}, nil
 }
 
-func (s *Server) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordRequest) (*pb.ForgotPasswordResponse, error) {
+func (s *Server) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordRequest) (res *pb.ForgotPasswordResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditForgotPassword)
+	defer func() { s.audit(ctx, event, res) }()
+
 	var user models.User
 
-	if result := s.R.DB.Where(&models.User{Email: req.Email}).First(&user); result.Error != nil {
+	email, err := utils.NormalizeEmail(req.Email)
+
+	if err != nil {
+		return &pb.ForgotPasswordResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByEmail(ctx, email); err != nil {
+		if s.enumerationSafe() {
+			return &pb.ForgotPasswordResponse{
+				Status: http.StatusOK,
+			}, nil
+		}
 		return &pb.ForgotPasswordResponse{
 			Status: http.StatusNotFound,
 			Error:  "Email was never registered",
 		}, nil
 	}
 
+	event.SubjectID = &user.ID
+
 	if !user.Confirmed {
+		if s.enumerationSafe() {
+			return &pb.ForgotPasswordResponse{
+				Status: http.StatusOK,
+			}, nil
+		}
 		return &pb.ForgotPasswordResponse{
 			Status: http.StatusForbidden,
 			Error:  "Email was never activated",
@@ -197,17 +384,19 @@ func (s *Server) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordReque
 	forgotToken, errToken := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "")
 
 	if errToken != nil {
-		defer logrus.Error(errToken.Error())
+		defer s.log().Error(errToken.Error())
 		return &pb.ForgotPasswordResponse{
 			Status: http.StatusBadRequest,
 			Error:  "Generate forgot access token failed",
 		}, nil
 	}
 
-	_, errSendMail := utils.SendGridMail(user.FirstName, user.Email, "Reset Password", "fogot", forgotToken, os.Getenv("SENDGRID_KEY"))
+	errSendMail := s.deliver(func() error {
+		return s.sendMail(ctx, user.FirstName, user.Email, "Reset Password", "forgot", forgotToken, os.Getenv("FORGOT_PASSWORD_URL"))
+	})
 
 	if errSendMail != nil {
-		defer logrus.Error(errSendMail.Error())
+		defer s.log().Error(errSendMail.Error())
 		return &pb.ForgotPasswordResponse{
 			Status: http.StatusBadRequest,
 			Error:  "Sending email for retrieving password failed",
@@ -220,7 +409,10 @@ func (s *Server) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordReque
 
 }
 
-func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (res *pb.ResetPasswordResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditResetPassword)
+	defer func() { s.audit(ctx, event, res) }()
+
 	var user models.User
 
 	claims, err := s.Jwt.ValidateToken(req.Token, "")
@@ -232,13 +424,15 @@ func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest
 		}, nil
 	}
 
-	if result := s.R.DB.Where(&models.User{Email: claims.Email}).First(&user); result.Error != nil {
+	if user, err = s.R.Users().FindByEmail(ctx, claims.Email); err != nil {
 		return &pb.ResetPasswordResponse{
 			Status: http.StatusNotFound,
 			Error:  "Email was never registered",
 		}, nil
 	}
 
+	auditUser(event, user.ID)
+
 	if req.Password != req.ConfirmPassword {
 		return &pb.ResetPasswordResponse{
 			Status: http.StatusForbidden,
@@ -246,9 +440,24 @@ func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest
 		}, nil
 	}
 
-	user.Password, _ = utils.HashPassword(req.Password)
+	password := utils.NormalizePassword(req.Password)
+
+	if violations := s.checkNewPassword(ctx, "password", password, user); len(violations) > 0 {
+		return &pb.ResetPasswordResponse{
+			Status:     http.StatusBadRequest,
+			Error:      "Password does not meet the requirements",
+			Violations: toPbViolations(violations),
+		}, nil
+	}
+
+	hash, err := s.hashPassword(ctx, password)
+
+	if err != nil {
+		return nil, err
+	}
 
-	if updateNewPassword := s.R.DB.Debug().Select("password", "update_at").Where("email = ?", claims.Email).Updates(user); updateNewPassword != nil {
+	if err := s.updatePassword(ctx, &user, hash); err != nil {
+		defer s.log().Error(err.Error())
 		return &pb.ResetPasswordResponse{
 			Status: http.StatusForbidden,
 			Error:  "Error occured during password reset",
@@ -256,13 +465,18 @@ func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest
 
 	}
 
+	metrics.PasswordResets.Inc()
+
 	return &pb.ResetPasswordResponse{
 		Status: http.StatusOK,
 	}, nil
 
 }
 
-func (s *Server) Refresh(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+func (s *Server) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (res *pb.RefreshTokenResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditRefreshToken)
+	defer func() { s.audit(ctx, event, res) }()
+
 	claims, err := s.Jwt.ValidateToken(req.RefreshToken, "REFRESH_TOKEN")
 
 	if err != nil {
@@ -274,14 +488,36 @@ func (s *Server) Refresh(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.
 
 	var user models.User
 
-	if result := s.R.DB.Where(&models.User{Email: claims.Email}).First(&user); result.Error != nil {
+	if user, err = s.R.Users().FindByID(ctx, claims.Id); err != nil {
 		return &pb.RefreshTokenResponse{
 			Status: http.StatusNotFound,
 			Error:  "User not found",
 		}, nil
 	}
 
-	newAccessToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "ACCESS_TOKEN")
+	auditUser(event, user.ID)
+
+	if user.DeleteAfter != nil {
+		return &pb.RefreshTokenResponse{
+			Status: http.StatusForbidden,
+			Error:  "Account is scheduled for deletion",
+		}, nil
+	}
+
+	session, errSession := s.activeSession(ctx, user.ID, claims.SessionId)
+
+	if errSession != nil {
+		return &pb.RefreshTokenResponse{
+			Status: http.StatusUnauthorized,
+			Error:  errSession.Error(),
+		}, nil
+	}
+
+	if errTouch := s.R.Sessions().Touch(ctx, session, event.ClientIP); errTouch != nil {
+		s.log().Error(errTouch.Error())
+	}
+
+	newAccessToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role, SessionId: session.ID}, "ACCESS_TOKEN")
 
 	return &pb.RefreshTokenResponse{
 		Status: http.StatusOK,
@@ -289,7 +525,10 @@ func (s *Server) Refresh(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.
 	}, nil
 }
 
-func (s *Server) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.ValidateResponse, error) {
+func (s *Server) Validate(ctx context.Context, req *pb.ValidateRequest) (res *pb.ValidateResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditValidate)
+	defer func() { s.audit(ctx, event, res) }()
+
 	tokenType := req.TokenType
 	claims, err := s.Jwt.ValidateToken(req.Token, tokenType)
 
@@ -301,32 +540,65 @@ func (s *Server) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.Val
 	}
 
 	var user models.User
-	if result := s.R.DB.Where(&models.User{ID: claims.Id}).First(&user); result.Error != nil {
+	if user, err = s.R.Users().FindByID(ctx, claims.Id); err != nil {
 		return &pb.ValidateResponse{
 			Status: http.StatusNotFound,
 			Error:  "User not found",
 		}, nil
 	}
 
+	auditUser(event, user.ID)
+
+	if user.DeleteAfter != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusForbidden,
+			Error:  "Account is scheduled for deletion",
+		}, nil
+	}
+
+	// access and refresh tokens are only valid as long as their session
+	if tokenType == "ACCESS_TOKEN" || tokenType == "REFRESH_TOKEN" {
+		if _, errSession := s.activeSession(ctx, user.ID, claims.SessionId); errSession != nil {
+			return &pb.ValidateResponse{
+				Status: http.StatusUnauthorized,
+				Error:  errSession.Error(),
+			}, nil
+		}
+	}
+
 	return &pb.ValidateResponse{
-		Status: http.StatusOK,
-		UserId: claims.Id.String(),
-		Role:   claims.Role,
+		Status:    http.StatusOK,
+		UserId:    claims.Id.String(),
+		Role:      claims.Role,
+		SessionId: claims.SessionId.String(),
 	}, nil
 }
 
-func (s *Server) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
+func (s *Server) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (res *pb.GetUsersResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditGetUsers)
+	defer func() { s.audit(ctx, event, res) }()
 
 	var user models.User
 	var users []models.User
 
-	if result := s.R.DB.Where(&models.User{ID: uuid.MustParse(req.UserID)}).First(&user); result.Error != nil {
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.GetUsersResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, userID); err != nil {
 		return &pb.GetUsersResponse{
 			Status: http.StatusNotFound,
 			Error:  "No such user",
 		}, nil
 	}
 
+	auditUser(event, user.ID)
+
 	if !user.Confirmed {
 		return &pb.GetUsersResponse{
 			Status: http.StatusConflict,
@@ -334,7 +606,7 @@ func (s *Server) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (*pb.Get
 		}, nil
 	}
 
-	if result := s.R.DB.Where(&models.User{Confirmed: true}).Find(&users); result.Error != nil {
+	if users, err = s.R.Users().ListConfirmed(ctx); err != nil {
 		return &pb.GetUsersResponse{
 			Status: http.StatusNotFound,
 			Error:  "No users at all found",
@@ -343,7 +615,7 @@ func (s *Server) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (*pb.Get
 
 	var resUsers []*pb.User
 	for _, u := range users {
-		resUsers = append(resUsers, &pb.User{ID: u.ID.String(), Name: fmt.Sprintf("%s %s", u.FirstName, u.LastName), Role: u.Role})
+		resUsers = append(resUsers, toPbUser(u))
 	}
 
 	return &pb.GetUsersResponse{
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *Server {
+	c := conf.Configuration{}
+	c.Password.HashAlgorithm = "bcrypt"
+	c.Password.BcryptCost = 4
+
+	return &Server{
+		R:      storage.NewMemoryRepository(),
+		Jwt:    utils.JwtWrapper{TokenKey: "test-secret", AccessTokenKey: "test-access-secret", RefreshTokenKey: "test-refresh-secret", Issuer: "lakelandcup-auth-service-test", ExpirationHours: 1},
+		Conf:   c,
+		Mailer: &recordingMailer{},
+	}
+}
+
+type sentMail struct {
+	email    string
+	fileName string
+	token    string
+}
+
+// recordingMailer keeps the mails of a test server instead of sending them
+type recordingMailer struct {
+	mu    sync.Mutex
+	mails []sentMail
+}
+
+func (m *recordingMailer) Send(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, sentMail{email: email, fileName: fileName, token: data["token"]})
+	return nil
+}
+
+// sent returns the mails of the given template in the order they were sent
+func (m *recordingMailer) sent(fileName string) []sentMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sent []sentMail
+	for _, mail := range m.mails {
+		if mail.fileName == fileName {
+			sent = append(sent, mail)
+		}
+	}
+	return sent
+}
+
+// token returns the token of the last mail of the given template
+func (m *recordingMailer) token(fileName string) string {
+	sent := m.sent(fileName)
+	if len(sent) == 0 {
+		return ""
+	}
+	return sent[len(sent)-1].token
+}
+
+func mails(s *Server) *recordingMailer {
+	return s.Mailer.(*recordingMailer)
+}
+
+func TestLoginAndSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+
+	hash, err := s.hashPassword(ctx, "correct horse battery staple")
+	assert.Nil(t, err)
+	user := models.User{Email: "ada@example.com", Password: hash, Confirmed: true}
+	assert.Nil(t, s.R.Users().Create(ctx, &user))
+
+	res, _ := s.Login(ctx, &pb.LoginRequest{Email: "ada@example.com", Password: "wrong password"})
+	assert.Equal(t, int64(http.StatusNotFound), res.Status)
+
+	res, _ = s.Login(ctx, &pb.LoginRequest{Email: "Ada@Example.com", Password: "correct horse battery staple"})
+	assert.Equal(t, int64(http.StatusOK), res.Status)
+	assert.Equal(t, user.ID.String(), res.UserId)
+	assert.NotEmpty(t, res.Token)
+
+	sessions, _ := s.ListSessions(ctx, &pb.ListSessionsRequest{UserID: user.ID.String()})
+	assert.Equal(t, int64(http.StatusOK), sessions.Status)
+	assert.Len(t, sessions.Sessions, 1)
+
+	revoked, _ := s.RevokeSession(ctx, &pb.RevokeSessionRequest{UserID: user.ID.String(), SessionID: sessions.Sessions[0].ID})
+	assert.Equal(t, int64(http.StatusOK), revoked.Status)
+
+	sessions, _ = s.ListSessions(ctx, &pb.ListSessionsRequest{UserID: user.ID.String()})
+	assert.Empty(t, sessions.Sessions)
+
+	attempts, _ := s.R.LoginAttempts().ListByUser(ctx, user.ID)
+	assert.Len(t, attempts, 2)
+	events, _ := s.R.AuditEvents().List(ctx, storage.AuditFilter{Type: models.AuditLogin})
+	assert.Len(t, events, 2)
+}
+
+func TestLoginUnconfirmed(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+
+	hash, _ := s.hashPassword(ctx, "correct horse battery staple")
+	assert.Nil(t, s.R.Users().Create(ctx, &models.User{Email: "ada@example.com", Password: hash}))
+
+	res, _ := s.Login(ctx, &pb.LoginRequest{Email: "ada@example.com", Password: "correct horse battery staple"})
+	assert.Equal(t, int64(http.StatusForbidden), res.Status)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+)
+
+// background runs fn outside of the request, e.g. to send a mail, and tracks
+// it so that shutdown can wait for it in WaitBackground
+func (s *Server) background(fn func()) {
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		fn()
+	}()
+}
+
+// WaitBackground waits until the work started in the background is done or ctx expires
+func (s *Server) WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
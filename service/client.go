@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/hiltpold/lakelandcup-auth-service/interceptors"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientInfo returns the ip address and user agent of the calling client. Calls
+// relayed by a trusted proxy carry the original address in their metadata, any
+// other caller is recorded with the address it connects from.
+func (s *Server) clientInfo(ctx context.Context) (ip string, userAgent string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	if s.trustedProxy(ctx, ip) {
+		if forwarded := md.Get("x-forwarded-for"); len(forwarded) > 0 {
+			ip = s.forwardedClient(forwarded[0])
+		} else if realIP := md.Get("x-real-ip"); len(realIP) > 0 {
+			ip = strings.TrimSpace(realIP[0])
+		}
+	}
+
+	return ip, clientUserAgent(md)
+}
+
+// trustedProxy reports whether the caller at ip may relay the address of the client,
+// either by its address or by the identity of its client certificate
+func (s *Server) trustedProxy(ctx context.Context, ip string) bool {
+	if utils.InNetworks(ip, s.Conf.API.TrustedProxies) {
+		return true
+	}
+
+	identity := interceptors.PeerIdentity(ctx)
+	if identity == "" {
+		return false
+	}
+	for _, trusted := range strings.Split(s.Conf.API.TrustedProxyIdentities, ",") {
+		if strings.TrimSpace(trusted) == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedClient returns the client of an x-forwarded-for chain, the last address not
+// added by a trusted proxy since clients can put anything in front of the chain
+func (s *Server) forwardedClient(forwarded string) string {
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i > 0; i-- {
+		if hop := strings.TrimSpace(hops[i]); !utils.InNetworks(hop, s.Conf.API.TrustedProxies) {
+			return hop
+		}
+	}
+	return strings.TrimSpace(hops[0])
+}
+
+func clientUserAgent(md metadata.MD) string {
+	if agent := md.Get("grpcgateway-user-agent"); len(agent) > 0 {
+		return agent[0]
+	} else if agent := md.Get("user-agent"); len(agent) > 0 {
+		return agent[0]
+	}
+	return ""
+}
+
+// deviceFingerprint derives an identifier of the calling device from its user agent
+// and the optional x-device-id the clients keep across sessions. The ip address is
+// left out on purpose since it changes whenever a device switches networks.
+func deviceFingerprint(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	userAgent := clientUserAgent(md)
+
+	deviceID := ""
+	if id := md.Get("x-device-id"); len(id) > 0 {
+		deviceID = id[0]
+	}
+
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(userAgent)) + "|" + deviceID))
+	return hex.EncodeToString(sum[:])
+}
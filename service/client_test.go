@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func withClient(addr string, pairs ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 4711}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+}
+
+func TestClientInfo(t *testing.T) {
+	s := &Server{}
+	s.Conf.API.TrustedProxies = "10.0.0.0/8"
+
+	// forwarded addresses of untrusted callers are ignored
+	ip, userAgent := s.clientInfo(withClient("203.0.113.7", "x-forwarded-for", "198.51.100.1", "user-agent", "curl/8.0"))
+	assert.Equal(t, "203.0.113.7", ip)
+	assert.Equal(t, "curl/8.0", userAgent)
+
+	ip, _ = s.clientInfo(withClient("203.0.113.7", "x-real-ip", "198.51.100.1"))
+	assert.Equal(t, "203.0.113.7", ip)
+
+	// behind a trusted proxy the client is the last hop it did not add itself
+	ip, _ = s.clientInfo(withClient("10.0.0.2", "x-forwarded-for", "198.51.100.1, 198.51.100.2, 10.0.0.3"))
+	assert.Equal(t, "198.51.100.2", ip)
+
+	ip, _ = s.clientInfo(withClient("10.0.0.2", "x-real-ip", "198.51.100.1"))
+	assert.Equal(t, "198.51.100.1", ip)
+
+	ip, _ = s.clientInfo(withClient("10.0.0.2"))
+	assert.Equal(t, "10.0.0.2", ip)
+}
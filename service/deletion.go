@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+)
+
+func (s *Server) deletionGracePeriod() time.Duration {
+	return time.Duration(utils.Ternary(s.Conf.API.DeletionGracePeriod > 0, s.Conf.API.DeletionGracePeriod, 30*24)) * time.Hour
+}
+
+func (s *Server) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (res *pb.DeleteAccountResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditDeleteAccount)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var user models.User
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, userID); err != nil {
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	// deleting an account requires the user to authenticate again
+	if match, _ := s.verifyPassword(ctx, req.Password, user.Password); !match {
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusForbidden,
+			Error:  "Incorrect password",
+		}, nil
+	}
+
+	if user.DeleteAfter != nil {
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusConflict,
+			Error:  "Account is already scheduled for deletion",
+		}, nil
+	}
+
+	// milliseconds are kept exactly by every database, the cancel token compares them
+	deleteAfter := time.Now().Local().Add(s.deletionGracePeriod()).Truncate(time.Millisecond)
+
+	// the cancel token is generated up front, nothing is changed if that fails. It only
+	// cancels this deletion, not one requested again after it was cancelled.
+	cancelToken, errToken := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role, DeleteAfter: deleteAfter}, "ACCOUNT_DELETION_CANCEL")
+
+	if errToken != nil {
+		defer s.log().Error(errToken.Error())
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Generate cancel token failed",
+		}, nil
+	}
+
+	user.DeleteAfter = &deleteAfter
+
+	if errUpdate := s.R.Users().Update(ctx, &user, "delete_after"); errUpdate != nil {
+		defer s.log().Error(errUpdate.Error())
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Account could not be scheduled for deletion",
+		}, nil
+	}
+
+	if errRevoke := s.revokeSessions(ctx, user.ID); errRevoke != nil {
+		defer s.log().Error(errRevoke.Error())
+		return &pb.DeleteAccountResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Sessions could not be revoked",
+		}, nil
+	}
+
+	// the deletion is scheduled at this point, a failed cancel mail must not report a failure
+	errSendMail := s.deliver(func() error {
+		return s.sendMail(ctx, user.FirstName, user.Email, "Account Deletion", "account-deletion", cancelToken, os.Getenv("ACCOUNT_DELETION_CANCEL_URL"))
+	})
+
+	if errSendMail != nil {
+		s.log().Error(errSendMail.Error())
+	}
+
+	return &pb.DeleteAccountResponse{
+		Status:      http.StatusOK,
+		DeleteAfter: deleteAfter.Format(time.RFC3339),
+	}, nil
+}
+
+func (s *Server) CancelAccountDeletion(ctx context.Context, req *pb.CancelAccountDeletionRequest) (res *pb.CancelAccountDeletionResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditCancelAccountDeletion)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var user models.User
+
+	claims, err := s.Jwt.ValidateToken(req.Token, "ACCOUNT_DELETION_CANCEL")
+
+	if err != nil {
+		return &pb.CancelAccountDeletionResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, claims.Id); err != nil {
+		return &pb.CancelAccountDeletionResponse{
+			Status: http.StatusNotFound,
+			Error:  "Token does not belong to a user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	if user.DeleteAfter == nil {
+		return &pb.CancelAccountDeletionResponse{
+			Status: http.StatusConflict,
+			Error:  "Account is not scheduled for deletion",
+		}, nil
+	}
+
+	if user.DeleteAfter.UnixMilli() != claims.DeleteAfter {
+		return &pb.CancelAccountDeletionResponse{
+			Status: http.StatusConflict,
+			Error:  "Token belongs to an earlier deletion request",
+		}, nil
+	}
+
+	user.DeleteAfter = nil
+
+	if errUpdate := s.R.Users().Update(ctx, &user, "delete_after"); errUpdate != nil {
+		defer s.log().Error(errUpdate.Error())
+		return &pb.CancelAccountDeletionResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Account deletion could not be cancelled",
+		}, nil
+	}
+
+	return &pb.CancelAccountDeletionResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// PurgeDeletedAccounts hard deletes or anonymizes, depending on the configured mode,
+// every account whose grace period has passed and returns the number of purged accounts.
+func (s *Server) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	users, err := s.R.Users().ListDeletionDue(ctx, time.Now().Local())
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range users {
+		if err := s.R.Users().Purge(ctx, &users[i], s.Conf.API.DeletionMode == "anonymize"); err != nil {
+			return i, err
+		}
+	}
+
+	return len(users), nil
+}
+
+// RunAccountPurge purges accounts past their grace period every interval until ctx is done.
+func (s *Server) RunAccountPurge(ctx context.Context, interval time.Duration) {
+	s.runEvery(ctx, interval, "Purging deleted accounts", s.PurgeDeletedAccounts)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string) error {
+	return errors.New("mail service unavailable")
+}
+
+func TestDeleteAccountWhenCancelMailFails(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	s.Mailer = failingMailer{}
+
+	hash, _ := s.hashPassword(ctx, "correct horse battery staple")
+	user := models.User{Email: "ada@example.com", Password: hash, Confirmed: true}
+	assert.Nil(t, s.R.Users().Create(ctx, &user))
+
+	// the deletion is scheduled even though the cancel mail could not be sent
+	res, _ := s.DeleteAccount(ctx, &pb.DeleteAccountRequest{UserID: user.ID.String(), Password: "correct horse battery staple"})
+	assert.Equal(t, int64(http.StatusOK), res.Status)
+	assert.NotEmpty(t, res.DeleteAfter)
+
+	found, _ := s.R.Users().FindByID(ctx, user.ID)
+	assert.NotNil(t, found.DeleteAfter)
+}
+
+func TestCancelAccountDeletion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+
+	hash, _ := s.hashPassword(ctx, "correct horse battery staple")
+	user := models.User{Email: "ada@example.com", Password: hash, Confirmed: true}
+	require.NoError(t, s.R.Users().Create(ctx, &user))
+
+	deleteAccount := &pb.DeleteAccountRequest{UserID: user.ID.String(), Password: "correct horse battery staple"}
+	res, _ := s.DeleteAccount(ctx, deleteAccount)
+	require.Equal(t, int64(http.StatusOK), res.Status)
+	earlier := mails(s).token("account-deletion")
+
+	cancelled, _ := s.CancelAccountDeletion(ctx, &pb.CancelAccountDeletionRequest{Token: earlier})
+	assert.Equal(t, int64(http.StatusOK), cancelled.Status)
+	cancelled, _ = s.CancelAccountDeletion(ctx, &pb.CancelAccountDeletionRequest{Token: earlier})
+	assert.Equal(t, int64(http.StatusConflict), cancelled.Status)
+
+	// the token of the cancelled deletion does not cancel the one requested again
+	res, _ = s.DeleteAccount(ctx, deleteAccount)
+	require.Equal(t, int64(http.StatusOK), res.Status)
+
+	cancelled, _ = s.CancelAccountDeletion(ctx, &pb.CancelAccountDeletionRequest{Token: earlier})
+	assert.Equal(t, int64(http.StatusConflict), cancelled.Status)
+	found, _ := s.R.Users().FindByID(ctx, user.ID)
+	assert.NotNil(t, found.DeleteAfter)
+
+	cancelled, _ = s.CancelAccountDeletion(ctx, &pb.CancelAccountDeletionRequest{Token: mails(s).token("account-deletion")})
+	assert.Equal(t, int64(http.StatusOK), cancelled.Status)
+	found, _ = s.R.Users().FindByID(ctx, user.ID)
+	assert.Nil(t, found.DeleteAfter)
+}
+
+// scheduleDeletions creates a user whose deletion is due and one whose grace period is still running
+func scheduleDeletions(t *testing.T, s *Server) (due models.User, pending models.User) {
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	due = models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", DeleteAfter: &past}
+	pending = models.User{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", DeleteAfter: &future}
+	require.NoError(t, s.R.Users().Create(ctx, &due))
+	require.NoError(t, s.R.Users().Create(ctx, &pending))
+
+	return due, pending
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	due, pending := scheduleDeletions(t, s)
+
+	purged, err := s.PurgeDeletedAccounts(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = s.R.Users().FindByID(ctx, due.ID)
+	assert.Equal(t, storage.ErrNotFound, err)
+	found, err := s.R.Users().FindByID(ctx, pending.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Grace", found.FirstName)
+
+	purged, _ = s.PurgeDeletedAccounts(ctx)
+	assert.Equal(t, 0, purged)
+}
+
+func TestPurgeDeletedAccountsAnonymize(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	s.Conf.API.DeletionMode = "anonymize"
+	due, pending := scheduleDeletions(t, s)
+
+	purged, err := s.PurgeDeletedAccounts(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+
+	found, err := s.R.Users().FindByID(ctx, due.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Deleted", found.FirstName)
+	assert.NotEqual(t, "ada@example.com", found.Email)
+	assert.Nil(t, found.DeleteAfter)
+
+	found, _ = s.R.Users().FindByID(ctx, pending.ID)
+	assert.Equal(t, "Grace", found.FirstName)
+	assert.NotNil(t, found.DeleteAfter)
+}
+
+func TestRunAccountPurge(t *testing.T) {
+	s := newTestServer()
+	due, pending := scheduleDeletions(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunAccountPurge(ctx, time.Hour)
+		close(done)
+	}()
+
+	// the first purge runs right away
+	assert.Eventually(t, func() bool {
+		_, err := s.R.Users().FindByID(context.Background(), due.ID)
+		return err == storage.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+	_, err := s.R.Users().FindByID(context.Background(), pending.ID)
+	assert.Nil(t, err)
+
+	cancel()
+	<-done
+}
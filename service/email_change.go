@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+)
+
+func (s *Server) emailChangeExpires() time.Duration {
+	return time.Duration(utils.Ternary(s.Conf.API.EmailChangeExpires > 0, s.Conf.API.EmailChangeExpires, 24)) * time.Hour
+}
+
+func (s *Server) emailChangeUndoExpires() time.Duration {
+	return time.Duration(utils.Ternary(s.Conf.API.EmailChangeUndoExpires > 0, s.Conf.API.EmailChangeUndoExpires, 72)) * time.Hour
+}
+
+func (s *Server) RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (res *pb.RequestEmailChangeResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditRequestEmailChange)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var user models.User
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, userID); err != nil {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	if !user.Confirmed {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusForbidden,
+			Error:  "User not yet Confirmed",
+		}, nil
+	}
+
+	if match, _ := s.verifyPassword(ctx, req.Password, user.Password); !match {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusForbidden,
+			Error:  "Incorrect password",
+		}, nil
+	}
+
+	email, err := utils.NormalizeEmail(req.Email)
+
+	if err != nil {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if email == strings.ToLower(user.Email) {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "New email must differ from the current one",
+		}, nil
+	}
+
+	if _, err = s.R.Users().FindByEmail(ctx, email); err == nil {
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email already exists",
+		}, nil
+	}
+
+	change := models.EmailChange{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  email,
+		ExpiresAt: time.Now().Local().Add(s.emailChangeExpires()),
+	}
+
+	if errCreate := s.R.EmailChanges().Create(ctx, &change); errCreate != nil {
+		defer s.log().Error(errCreate.Error())
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Request email change failed",
+		}, nil
+	}
+
+	changeToken, errToken := s.Jwt.GenerateToken(utils.JwtData{Id: change.ID, Email: change.NewEmail}, "EMAIL_CHANGE")
+
+	if errToken != nil {
+		defer s.log().Error(errToken.Error())
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Generate email change token failed",
+		}, nil
+	}
+
+	errSendMail := s.sendMail(ctx, user.FirstName, change.NewEmail, "Confirm Email Change", "email-change", changeToken, os.Getenv("EMAIL_CHANGE_URL"))
+
+	if errSendMail != nil {
+		defer s.log().Error(errSendMail.Error())
+		return &pb.RequestEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Sending email change confirmation failed",
+		}, nil
+	}
+
+	// the notice to the current address is informational only, the change is still pending
+	if errSendMail := s.sendMail(ctx, user.FirstName, user.Email, "Email Change Requested", "email-change-notice", "", ""); errSendMail != nil {
+		s.log().Error(errSendMail.Error())
+	}
+
+	return &pb.RequestEmailChangeResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (res *pb.ConfirmEmailChangeResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditConfirmEmailChange)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var change models.EmailChange
+	var user models.User
+
+	claims, err := s.Jwt.ValidateToken(req.Token, "EMAIL_CHANGE")
+
+	if err != nil {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if change, err = s.R.EmailChanges().FindByID(ctx, claims.Id); err != nil || change.NewEmail != claims.Email {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusNotFound,
+			Error:  "Token does not belong to an email change",
+		}, nil
+	}
+
+	if change.ConfirmedAt != nil {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email change already confirmed",
+		}, nil
+	}
+
+	if time.Now().Local().After(change.ExpiresAt) {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusGone,
+			Error:  "Email change has expired",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, change.UserID); err != nil {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	if user.Email != change.OldEmail {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email was changed in the meantime",
+		}, nil
+	}
+
+	// the new address might have been registered since the change was requested
+	if _, err = s.R.Users().FindByEmail(ctx, change.NewEmail); err == nil {
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email already exists",
+		}, nil
+	}
+
+	confirmedAt := time.Now().Local()
+	undoExpiresAt := confirmedAt.Add(s.emailChangeUndoExpires())
+
+	change.ConfirmedAt = &confirmedAt
+	change.UndoExpiresAt = &undoExpiresAt
+
+	errUpdate := s.R.EmailChanges().Apply(ctx, &change, &user, change.NewEmail)
+
+	if errUpdate != nil {
+		defer s.log().Error(errUpdate.Error())
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email could not be changed",
+		}, nil
+	}
+
+	undoToken, errToken := s.Jwt.GenerateToken(utils.JwtData{Id: change.ID, Email: change.OldEmail}, "EMAIL_CHANGE_UNDO")
+
+	if errToken != nil {
+		defer s.log().Error(errToken.Error())
+		return &pb.ConfirmEmailChangeResponse{
+			Status: http.StatusOK,
+		}, nil
+	}
+
+	// the change is applied at this point, a failed undo mail must not report a failure
+	if errSendMail := s.sendMail(ctx, user.FirstName, change.OldEmail, "Your Email Was Changed", "email-change-undo", undoToken, os.Getenv("EMAIL_CHANGE_UNDO_URL")); errSendMail != nil {
+		s.log().Error(errSendMail.Error())
+	}
+
+	return &pb.ConfirmEmailChangeResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) UndoEmailChange(ctx context.Context, req *pb.UndoEmailChangeRequest) (res *pb.UndoEmailChangeResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditUndoEmailChange)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var change models.EmailChange
+	var user models.User
+
+	claims, err := s.Jwt.ValidateToken(req.Token, "EMAIL_CHANGE_UNDO")
+
+	if err != nil {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if change, err = s.R.EmailChanges().FindByID(ctx, claims.Id); err != nil || change.OldEmail != claims.Email {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusNotFound,
+			Error:  "Token does not belong to an email change",
+		}, nil
+	}
+
+	if change.ConfirmedAt == nil || change.UndoExpiresAt == nil {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email change was never confirmed",
+		}, nil
+	}
+
+	if change.RevertedAt != nil {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email change already undone",
+		}, nil
+	}
+
+	if time.Now().Local().After(*change.UndoExpiresAt) {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusGone,
+			Error:  "Undo window has expired",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, change.UserID); err != nil {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	if user.Email != change.NewEmail {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email was changed in the meantime",
+		}, nil
+	}
+
+	if _, err = s.R.Users().FindByEmail(ctx, change.OldEmail); err == nil {
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email already exists",
+		}, nil
+	}
+
+	revertedAt := time.Now().Local()
+
+	change.RevertedAt = &revertedAt
+
+	errUpdate := s.R.EmailChanges().Apply(ctx, &change, &user, change.OldEmail)
+
+	if errUpdate != nil {
+		defer s.log().Error(errUpdate.Error())
+		return &pb.UndoEmailChangeResponse{
+			Status: http.StatusConflict,
+			Error:  "Email change could not be undone",
+		}, nil
+	}
+
+	return &pb.UndoEmailChangeResponse{
+		Status: http.StatusOK,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createConfirmedUser(t *testing.T, s *Server, email string) models.User {
+	ctx := context.Background()
+
+	hash, err := s.hashPassword(ctx, "correct horse battery staple")
+	require.NoError(t, err)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Email: email, Password: hash, Confirmed: true}
+	require.NoError(t, s.R.Users().Create(ctx, &user))
+	return user
+}
+
+func requestEmailChange(t *testing.T, s *Server, user models.User, email string) *pb.RequestEmailChangeResponse {
+	res, err := s.RequestEmailChange(context.Background(), &pb.RequestEmailChangeRequest{UserID: user.ID.String(), Email: email, Password: "correct horse battery staple"})
+	require.NoError(t, err)
+	return res
+}
+
+func TestEmailChangeAndUndo(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	res, _ := s.RequestEmailChange(ctx, &pb.RequestEmailChangeRequest{UserID: user.ID.String(), Email: "countess@example.com", Password: "wrong password"})
+	assert.Equal(t, int64(http.StatusForbidden), res.Status)
+	assert.Equal(t, int64(http.StatusBadRequest), requestEmailChange(t, s, user, "ADA@example.com").Status)
+
+	require.Equal(t, int64(http.StatusOK), requestEmailChange(t, s, user, "Countess@Example.com").Status)
+	confirmation := mails(s).sent("email-change")
+	require.Len(t, confirmation, 1)
+	assert.Equal(t, "countess@example.com", confirmation[0].email)
+	notice := mails(s).sent("email-change-notice")
+	require.Len(t, notice, 1)
+	assert.Equal(t, "ada@example.com", notice[0].email)
+
+	// the email stays until the new address is confirmed
+	found, _ := s.R.Users().FindByID(ctx, user.ID)
+	assert.Equal(t, "ada@example.com", found.Email)
+
+	confirmed, _ := s.ConfirmEmailChange(ctx, &pb.ConfirmEmailChangeRequest{Token: confirmation[0].token})
+	assert.Equal(t, int64(http.StatusOK), confirmed.Status)
+	found, _ = s.R.Users().FindByID(ctx, user.ID)
+	assert.Equal(t, "countess@example.com", found.Email)
+
+	confirmed, _ = s.ConfirmEmailChange(ctx, &pb.ConfirmEmailChangeRequest{Token: confirmation[0].token})
+	assert.Equal(t, int64(http.StatusConflict), confirmed.Status)
+
+	// the undo link goes to the former address
+	undo := mails(s).sent("email-change-undo")
+	require.Len(t, undo, 1)
+	assert.Equal(t, "ada@example.com", undo[0].email)
+
+	undone, _ := s.UndoEmailChange(ctx, &pb.UndoEmailChangeRequest{Token: undo[0].token})
+	assert.Equal(t, int64(http.StatusOK), undone.Status)
+	found, _ = s.R.Users().FindByID(ctx, user.ID)
+	assert.Equal(t, "ada@example.com", found.Email)
+
+	undone, _ = s.UndoEmailChange(ctx, &pb.UndoEmailChangeRequest{Token: undo[0].token})
+	assert.Equal(t, int64(http.StatusConflict), undone.Status)
+}
+
+func TestEmailChangeTakenMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+	createConfirmedUser(t, s, "grace@example.com")
+
+	assert.Equal(t, int64(http.StatusConflict), requestEmailChange(t, s, user, "grace@example.com").Status)
+
+	// the new address is registered between the request and the confirmation
+	require.Equal(t, int64(http.StatusOK), requestEmailChange(t, s, user, "countess@example.com").Status)
+	createConfirmedUser(t, s, "countess@example.com")
+
+	confirmed, _ := s.ConfirmEmailChange(ctx, &pb.ConfirmEmailChangeRequest{Token: mails(s).token("email-change")})
+	assert.Equal(t, int64(http.StatusConflict), confirmed.Status)
+	assert.Equal(t, "Email already exists", confirmed.Error)
+
+	found, _ := s.R.Users().FindByID(ctx, user.ID)
+	assert.Equal(t, "ada@example.com", found.Email)
+}
+
+func TestEmailChangeExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	expired := models.EmailChange{UserID: user.ID, OldEmail: user.Email, NewEmail: "countess@example.com", ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, s.R.EmailChanges().Create(ctx, &expired))
+	changeToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: expired.ID, Email: expired.NewEmail}, "EMAIL_CHANGE")
+
+	confirmed, _ := s.ConfirmEmailChange(ctx, &pb.ConfirmEmailChangeRequest{Token: changeToken})
+	assert.Equal(t, int64(http.StatusGone), confirmed.Status)
+
+	// the undo window of a confirmed change has passed
+	confirmedAt := time.Now().Add(-time.Hour)
+	undoExpiresAt := time.Now().Add(-time.Minute)
+	applied := models.EmailChange{UserID: user.ID, OldEmail: "former@example.com", NewEmail: user.Email, ExpiresAt: confirmedAt, ConfirmedAt: &confirmedAt, UndoExpiresAt: &undoExpiresAt}
+	require.NoError(t, s.R.EmailChanges().Create(ctx, &applied))
+	undoToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: applied.ID, Email: applied.OldEmail}, "EMAIL_CHANGE_UNDO")
+
+	undone, _ := s.UndoEmailChange(ctx, &pb.UndoEmailChangeRequest{Token: undoToken})
+	assert.Equal(t, int64(http.StatusGone), undone.Status)
+
+	// tokens of one purpose are refused for the other
+	undone, _ = s.UndoEmailChange(ctx, &pb.UndoEmailChangeRequest{Token: changeToken})
+	assert.Equal(t, int64(http.StatusBadRequest), undone.Status)
+	assert.Equal(t, "JWT was issued for another purpose", undone.Error)
+}
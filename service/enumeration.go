@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hiltpold/lakelandcup-auth-service/tracing"
+)
+
+// dummyHashes caches a hash per hasher configuration to verify passwords of unknown emails against
+var dummyHashes sync.Map
+
+// enumerationSafe reports whether responses must not reveal if an email is registered
+func (s *Server) enumerationSafe() bool {
+	return s.Conf.API.EnumerationSafe
+}
+
+// dummyVerify takes as long as verifying the password of an existing user, so the
+// response time does not reveal that the email is unknown
+func (s *Server) dummyVerify(ctx context.Context, password string) {
+	_, span := tracing.Tracer.Start(ctx, "password.verify")
+	defer span.End()
+
+	hasher := s.hasher()
+	key := fmt.Sprintf("%#v", hasher.Current)
+
+	encoded, ok := dummyHashes.Load(key)
+	if !ok {
+		hash, err := hasher.Hash("lakelandcup-dummy-password")
+		if err != nil {
+			return
+		}
+		encoded, _ = dummyHashes.LoadOrStore(key, hash)
+	}
+
+	hasher.Verify(password, encoded.(string))
+}
+
+// deliver sends a mail. In enumeration safe mode it is sent in the background and
+// failures are only logged, so neither timing nor errors reveal whether a mail was sent.
+func (s *Server) deliver(send func() error) error {
+	if !s.enumerationSafe() {
+		return send()
+	}
+
+	s.background(func() {
+		if err := send(); err != nil {
+			s.log().Error(err.Error())
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEnumerationSafeResponses(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	s.Conf.API.EnumerationSafe = true
+
+	ada := createConfirmedUser(t, s, "ada@example.com")
+	require.NoError(t, s.R.Users().Create(ctx, &models.User{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com"}))
+
+	// a known, an unconfirmed and an unknown email get the same answers
+	var registered, forgot, resent []proto.Message
+	for _, email := range []string{"ada@example.com", "grace@example.com", "alan@example.com"} {
+		res, err := s.Register(ctx, &pb.RegisterRequest{FirstName: "Alan", LastName: "Turing", Email: email, Password: "violet meadow one"})
+		require.NoError(t, err)
+		registered = append(registered, res)
+
+		forgotRes, err := s.ForgotPassword(ctx, &pb.ForgotPasswordRequest{Email: email})
+		require.NoError(t, err)
+		forgot = append(forgot, forgotRes)
+
+		resentRes, err := s.ResendActivationToken(ctx, &pb.ResendActivationTokenRequest{Email: email})
+		require.NoError(t, err)
+		resent = append(resent, resentRes)
+	}
+	for _, responses := range [][]proto.Message{registered, forgot, resent} {
+		for _, res := range responses[1:] {
+			assert.True(t, proto.Equal(responses[0], res), "%v differs from %v", res, responses[0])
+		}
+	}
+	assert.Equal(t, int64(http.StatusCreated), registered[0].(*pb.RegisterResponse).Status)
+
+	// the owner of the registered address is told about the attempt instead of a conflict
+	require.NoError(t, s.WaitBackground(ctx))
+	attempts := mails(s).sent("register-attempt")
+	require.Len(t, attempts, 2)
+	assert.ElementsMatch(t, []string{"ada@example.com", "grace@example.com"}, []string{attempts[0].email, attempts[1].email})
+
+	found, _ := s.R.Users().FindByEmail(ctx, "ada@example.com")
+	assert.Equal(t, ada.ID, found.ID)
+	assert.Equal(t, "Ada", found.FirstName)
+
+	// only the confirmed address gets a reset link
+	resets := mails(s).sent("forgot")
+	require.Len(t, resets, 1)
+	assert.Equal(t, "ada@example.com", resets[0].email)
+}
+
+func TestRegisterExistingEmail(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	createConfirmedUser(t, s, "ada@example.com")
+
+	res, _ := s.Register(ctx, &pb.RegisterRequest{FirstName: "Alan", LastName: "Turing", Email: "Ada@Example.com", Password: "violet meadow one"})
+	assert.Equal(t, int64(http.StatusConflict), res.Status)
+	assert.Empty(t, mails(s).sent("register-attempt"))
+}
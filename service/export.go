@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+)
+
+const exportChunkSize = 32 * 1024
+
+// exportUser shadows the password hash of the embedded user so it is never exported
+type exportUser struct {
+	models.User
+	Password string `json:"password,omitempty"`
+}
+
+// exportDocument holds everything the service stores about a user
+type exportDocument struct {
+	ExportedAt   time.Time             `json:"exportedAt"`
+	User         exportUser            `json:"user"`
+	EmailChanges []models.EmailChange  `json:"emailChanges"`
+	Sessions     []models.Session      `json:"sessions"`
+	Logins       []models.LoginAttempt `json:"logins"`
+	AuditEvents  []models.AuditEvent   `json:"auditEvents"`
+}
+
+type exportStream interface {
+	Send(*pb.ExportChunk) error
+}
+
+func (s *Server) exportDocument(ctx context.Context, user models.User) (doc *exportDocument, err error) {
+	doc = &exportDocument{
+		ExportedAt:   time.Now().Local(),
+		User:         exportUser{User: user},
+		EmailChanges: []models.EmailChange{},
+		Sessions:     []models.Session{},
+		Logins:       []models.LoginAttempt{},
+		AuditEvents:  []models.AuditEvent{},
+	}
+
+	if doc.EmailChanges, err = s.R.EmailChanges().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if doc.Sessions, err = s.R.Sessions().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if doc.Logins, err = s.R.LoginAttempts().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if doc.AuditEvents, err = s.R.AuditEvents().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// streamExport sends the export of the given user as JSON in chunks of exportChunkSize.
+// The returned chunk carries the overall status of the export.
+func (s *Server) streamExport(ctx context.Context, user models.User, stream exportStream) (*pb.ExportChunk, error) {
+	doc, err := s.exportDocument(ctx, user)
+
+	if err != nil {
+		defer s.log().Error(err.Error())
+		res := &pb.ExportChunk{
+			Status: http.StatusInternalServerError,
+			Error:  "Collecting user data failed",
+		}
+		return res, stream.Send(res)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+
+	if err != nil {
+		defer s.log().Error(err.Error())
+		res := &pb.ExportChunk{
+			Status: http.StatusInternalServerError,
+			Error:  "Encoding user data failed",
+		}
+		return res, stream.Send(res)
+	}
+
+	for start := 0; start < len(data); start += exportChunkSize {
+		end := start + exportChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := stream.Send(&pb.ExportChunk{Status: http.StatusOK, Data: data[start:end]}); err != nil {
+			return &pb.ExportChunk{Status: http.StatusInternalServerError, Error: "Sending user data failed"}, err
+		}
+	}
+
+	return &pb.ExportChunk{Status: http.StatusOK}, nil
+}
+
+func (s *Server) ExportMyData(req *pb.ExportMyDataRequest, stream pb.AuthService_ExportMyDataServer) (err error) {
+	var user models.User
+	var res *pb.ExportChunk
+
+	ctx := stream.Context()
+	event := s.newAuditEvent(ctx, models.AuditExportData)
+	defer func() { s.audit(ctx, event, res) }()
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		res = &pb.ExportChunk{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}
+		return stream.Send(res)
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, userID); err != nil {
+		res = &pb.ExportChunk{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}
+		return stream.Send(res)
+	}
+
+	auditUser(event, user.ID)
+
+	res, err = s.streamExport(ctx, user, stream)
+	return err
+}
+
+func (s *Server) ExportUserData(req *pb.ExportUserDataRequest, stream pb.AuthService_ExportUserDataServer) (err error) {
+	var admin models.User
+	var user models.User
+	var res *pb.ExportChunk
+
+	ctx := stream.Context()
+	event := s.newAuditEvent(ctx, models.AuditExportData)
+	defer func() { s.audit(ctx, event, res) }()
+
+	adminID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		res = &pb.ExportChunk{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}
+		return stream.Send(res)
+	}
+
+	event.ActorID = &adminID
+
+	if admin, err = s.R.Users().FindByID(ctx, adminID); err != nil || admin.Role != models.RoleAdmin {
+		res = &pb.ExportChunk{
+			Status: http.StatusForbidden,
+			Error:  "Only admins can export other users",
+		}
+		return stream.Send(res)
+	}
+
+	subjectID, err := uuid.Parse(req.SubjectUserID)
+
+	if err != nil {
+		res = &pb.ExportChunk{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid subject user id",
+		}
+		return stream.Send(res)
+	}
+
+	event.SubjectID = &subjectID
+
+	if user, err = s.R.Users().FindByID(ctx, subjectID); err != nil {
+		res = &pb.ExportChunk{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}
+		return stream.Send(res)
+	}
+
+	res, err = s.streamExport(ctx, user, stream)
+	return err
+}
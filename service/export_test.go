@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// exportRecorder collects the chunks of an export stream
+type exportRecorder struct {
+	grpc.ServerStream
+	chunks []*pb.ExportChunk
+}
+
+func (r *exportRecorder) Context() context.Context {
+	return context.Background()
+}
+
+func (r *exportRecorder) Send(chunk *pb.ExportChunk) error {
+	r.chunks = append(r.chunks, chunk)
+	return nil
+}
+
+// document reassembles the streamed chunks
+func (r *exportRecorder) document(t *testing.T) (data []byte) {
+	for _, chunk := range r.chunks {
+		require.Equal(t, int64(http.StatusOK), chunk.Status, chunk.Error)
+		data = append(data, chunk.Data...)
+	}
+	return data
+}
+
+func TestExportMyData(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	// enough events to need more than one chunk
+	for i := 0; i < 200; i++ {
+		require.NoError(t, s.R.AuditEvents().Create(ctx, &models.AuditEvent{Type: models.AuditLogin, Outcome: models.AuditOutcomeSuccess, SubjectID: &user.ID, UserAgent: "Mozilla/5.0 (X11; Linux x86_64)"}))
+	}
+
+	stream := &exportRecorder{}
+	require.NoError(t, s.ExportMyData(&pb.ExportMyDataRequest{UserID: user.ID.String()}, stream))
+	assert.Greater(t, len(stream.chunks), 1)
+
+	data := stream.document(t)
+	assert.NotContains(t, string(data), user.Password)
+
+	var doc struct {
+		User        map[string]interface{}   `json:"user"`
+		AuditEvents []map[string]interface{} `json:"auditEvents"`
+		Sessions    []map[string]interface{} `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "ada@example.com", doc.User["email"])
+	assert.NotContains(t, doc.User, "password")
+	assert.Len(t, doc.AuditEvents, 200)
+	assert.NotNil(t, doc.Sessions)
+}
+
+func TestExportUserData(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+	admin := createConfirmedUser(t, s, "grace@example.com")
+
+	stream := &exportRecorder{}
+	require.NoError(t, s.ExportUserData(&pb.ExportUserDataRequest{UserID: admin.ID.String(), SubjectUserID: user.ID.String()}, stream))
+	require.Len(t, stream.chunks, 1)
+	assert.Equal(t, int64(http.StatusForbidden), stream.chunks[0].Status)
+	assert.Empty(t, stream.chunks[0].Data)
+
+	admin.Role = models.RoleAdmin
+	require.NoError(t, s.R.Users().Update(ctx, &admin, "role"))
+
+	stream = &exportRecorder{}
+	require.NoError(t, s.ExportUserData(&pb.ExportUserDataRequest{UserID: admin.ID.String(), SubjectUserID: user.ID.String()}, stream))
+
+	var doc exportDocument
+	require.NoError(t, json.Unmarshal(stream.document(t), &doc))
+	assert.Equal(t, user.ID, doc.User.ID)
+	assert.Empty(t, doc.User.Password)
+
+	// exporting another user is recorded for the subject with the admin as actor
+	events, _ := s.R.AuditEvents().ListByUser(ctx, user.ID)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.AuditExportData, events[0].Type)
+	assert.Equal(t, admin.ID, *events[0].ActorID)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+// HealthReporter receives the serving status of the service, e.g. a grpc health.Server
+type HealthReporter interface {
+	SetServingStatus(service string, status healthpb.HealthCheckResponse_ServingStatus)
+}
+
+// checkHealth returns an error if the database or, if configured, the mailer is unreachable
+func (s *Server) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if err := s.R.Ping(ctx); err != nil {
+		return err
+	}
+
+	if s.Conf.Mail.SGSecretKey != "" {
+		if err := utils.SendGridReachable(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunHealthChecks reports the service as serving while the database and the mailer
+// are reachable, checking them every interval until ctx is done.
+func (s *Server) RunHealthChecks(ctx context.Context, interval time.Duration, reporter HealthReporter) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	serving := true
+	for {
+		err := s.checkHealth(ctx)
+		status := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		if err != nil && serving {
+			s.log().Warn("Health check failed, not serving", zap.Error(err))
+		} else if err == nil && !serving {
+			s.log().Info("Health check passed, serving again")
+		}
+		serving = err == nil
+
+		reporter.SetServingStatus("", status)
+		reporter.SetServingStatus(pb.AuthService_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
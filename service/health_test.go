@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// unreachableRepository fails to ping while down is set
+type unreachableRepository struct {
+	storage.Repository
+	mu   sync.Mutex
+	down bool
+}
+
+func (r *unreachableRepository) Ping(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (r *unreachableRepository) setDown(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+}
+
+func servingStatus(healthServer *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	res, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN
+	}
+	return res.Status
+}
+
+func TestRunHealthChecks(t *testing.T) {
+	s := newTestServer()
+	r := &unreachableRepository{Repository: s.R}
+	s.R = r
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(pb.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_UNKNOWN)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunHealthChecks(ctx, 10*time.Millisecond, healthServer)
+		close(done)
+	}()
+
+	isServing := func(status healthpb.HealthCheckResponse_ServingStatus) func() bool {
+		return func() bool {
+			return servingStatus(healthServer, "") == status && servingStatus(healthServer, pb.AuthService_ServiceDesc.ServiceName) == status
+		}
+	}
+
+	assert.Eventually(t, isServing(healthpb.HealthCheckResponse_SERVING), time.Second, 5*time.Millisecond)
+
+	r.setDown(true)
+	assert.Eventually(t, isServing(healthpb.HealthCheckResponse_NOT_SERVING), time.Second, 5*time.Millisecond)
+
+	r.setDown(false)
+	assert.Eventually(t, isServing(healthpb.HealthCheckResponse_SERVING), time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+}
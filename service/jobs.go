@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runEvery runs job immediately and then every interval until ctx is done.
+// The job returns the number of affected rows which is logged if non-zero.
+func (s *Server) runEvery(ctx context.Context, interval time.Duration, name string, job func(context.Context) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		affected, err := job(ctx)
+		if err != nil {
+			s.log().Error(name+" failed", zap.Error(err))
+		} else if affected > 0 {
+			s.log().Info(name, zap.Int("affected", affected))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.uber.org/zap"
+)
+
+const maxLogins = 100
+
+// recordLogin stores the login attempt described by the audit event of the Login call
+func (s *Server) recordLogin(ctx context.Context, event *models.AuditEvent, email string, fingerprint string, res *pb.LoginResponse) {
+	attempt := models.LoginAttempt{
+		UserID:      event.SubjectID,
+		Email:       truncate(email, 255),
+		Success:     res.GetStatus() == http.StatusOK,
+		ClientIP:    event.ClientIP,
+		UserAgent:   event.UserAgent,
+		Fingerprint: fingerprint,
+	}
+
+	if err := s.R.LoginAttempts().Create(ctx, &attempt); err != nil {
+		s.log().Error("Writing login attempt failed", zap.Error(err))
+	}
+}
+
+// isNewDevice reports whether the user has signed in before, but never from the given device
+func (s *Server) isNewDevice(ctx context.Context, userID uuid.UUID, fingerprint string) bool {
+	if logins, err := s.R.LoginAttempts().CountSuccessful(ctx, userID, ""); err != nil || logins == 0 {
+		return false
+	}
+
+	fromDevice, err := s.R.LoginAttempts().CountSuccessful(ctx, userID, fingerprint)
+	if err != nil {
+		return false
+	}
+
+	return fromDevice == 0
+}
+
+// notifyNewDevice tells the user about a sign-in from an unrecognized device
+func (s *Server) notifyNewDevice(ctx context.Context, user models.User, userAgent string, ip string) {
+	errSendMail := s.mailer().Send(ctx, user.FirstName, user.Email, "New Sign-In", "new-device", map[string]string{
+		"device": utils.Ternary(userAgent != "", truncate(userAgent, 200), "unknown device"),
+		"ip":     truncate(ip, 64),
+		"time":   time.Now().Local().Format(time.RFC1123),
+	})
+
+	if errSendMail != nil {
+		s.log().Error("Sending new device notification failed", zap.Error(errSendMail))
+	}
+}
+
+func toPbLogin(a models.LoginAttempt) *pb.Login {
+	return &pb.Login{
+		ID:          a.ID.String(),
+		Success:     a.Success,
+		ClientIP:    a.ClientIP,
+		UserAgent:   a.UserAgent,
+		Fingerprint: a.Fingerprint,
+		CreatedAt:   a.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func (s *Server) ListMyLogins(ctx context.Context, req *pb.ListMyLoginsRequest) (res *pb.ListMyLoginsResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditListMyLogins)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var attempts []models.LoginAttempt
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.ListMyLoginsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	auditUser(event, userID)
+
+	limit := utils.Ternary(req.Limit > 0 && req.Limit <= maxLogins, int(req.Limit), maxLogins)
+
+	if attempts, err = s.R.LoginAttempts().Recent(ctx, userID, limit); err != nil {
+		defer s.log().Error(err.Error())
+		return &pb.ListMyLoginsResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Listing logins failed",
+		}, nil
+	}
+
+	var logins []*pb.Login
+	for _, a := range attempts {
+		logins = append(logins, toPbLogin(a))
+	}
+
+	return &pb.ListMyLoginsResponse{
+		Status: http.StatusOK,
+		Logins: logins,
+	}, nil
+}
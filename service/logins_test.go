@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeviceNotification(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	login := func(userAgent string, password string) int64 {
+		res, err := s.Login(withClient("203.0.113.7", "user-agent", userAgent), &pb.LoginRequest{Email: "ada@example.com", Password: password})
+		require.NoError(t, err)
+		require.NoError(t, s.WaitBackground(ctx))
+		return res.Status
+	}
+
+	// the first device is not new, there is nothing to compare it to
+	assert.Equal(t, int64(http.StatusOK), login("Firefox", "correct horse battery staple"))
+	assert.Equal(t, int64(http.StatusOK), login("Firefox", "correct horse battery staple"))
+	assert.Empty(t, mails(s).sent("new-device"))
+
+	assert.Equal(t, int64(http.StatusOK), login("Safari", "correct horse battery staple"))
+	assert.Equal(t, int64(http.StatusOK), login("Safari", "correct horse battery staple"))
+	assert.Len(t, mails(s).sent("new-device"), 1)
+
+	// a failed attempt does not make a device known
+	assert.Equal(t, int64(http.StatusNotFound), login("curl/8.0", "wrong password"))
+	assert.Len(t, mails(s).sent("new-device"), 1)
+	assert.Equal(t, int64(http.StatusOK), login("curl/8.0", "correct horse battery staple"))
+
+	sent := mails(s).sent("new-device")
+	require.Len(t, sent, 2)
+	assert.Equal(t, "ada@example.com", sent[1].email)
+
+	logins, _ := s.ListMyLogins(ctx, &pb.ListMyLoginsRequest{UserID: user.ID.String(), Limit: 2})
+	require.Len(t, logins.Logins, 2)
+	assert.True(t, logins.Logins[0].Success)
+	assert.Equal(t, "curl/8.0", logins.Logins[0].UserAgent)
+	assert.False(t, logins.Logins[1].Success)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/tracing"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+)
+
+// hasher returns the password hasher for the configured algorithm and parameters,
+// argon2id with the second recommended parameters of RFC 9106 unless configured otherwise.
+func (s *Server) hasher() *utils.PasswordHasher {
+	c := s.Conf.Password
+
+	if c.HashAlgorithm == "bcrypt" {
+		return utils.NewPasswordHasher(utils.BcryptHasher{
+			Cost: utils.Ternary(c.BcryptCost > 0, c.BcryptCost, 12),
+		})
+	}
+
+	return utils.NewPasswordHasher(utils.Argon2idHasher{
+		Memory:      uint32(utils.Ternary(c.Argon2Memory > 0, c.Argon2Memory, 64*1024)),
+		Time:        uint32(utils.Ternary(c.Argon2Time > 0, c.Argon2Time, 3)),
+		Parallelism: uint8(utils.Ternary(c.Argon2Parallelism > 0, c.Argon2Parallelism, 4)),
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+}
+
+// hashPassword hashes the normalized password with the configured hasher
+func (s *Server) hashPassword(ctx context.Context, password string) (hash string, err error) {
+	_, span := tracing.Tracer.Start(ctx, "password.hash")
+	defer func() { tracing.End(span, err) }()
+
+	return s.hasher().Hash(password)
+}
+
+// passwordPolicy returns the configured policy, bcrypt only considers the first 72 bytes of a password
+func (s *Server) passwordPolicy() utils.PasswordPolicy {
+	c := s.Conf.Password
+
+	return utils.PasswordPolicy{
+		MinLength:        utils.Ternary(c.MinLength > 0, c.MinLength, 8),
+		MaxLength:        utils.Ternary(c.MaxLength > 0, c.MaxLength, 128),
+		MaxBytes:         utils.Ternary(c.HashAlgorithm == "bcrypt", 72, 0),
+		RequireLower:     c.RequireLower,
+		RequireUpper:     c.RequireUpper,
+		RequireDigit:     c.RequireDigit,
+		RequireSymbol:    c.RequireSymbol,
+		DisallowPersonal: !c.AllowPersonal,
+	}
+}
+
+// checkNewPassword returns the violations of a normalized password chosen by the user,
+// including its appearance in the breach index and the password history
+func (s *Server) checkNewPassword(ctx context.Context, field string, password string, user models.User) []utils.FieldViolation {
+	localPart := strings.Split(user.Email, "@")[0]
+	violations := s.passwordPolicy().Check(field, password, localPart, user.FirstName, user.LastName)
+
+	if s.Breached.Contains(password) {
+		violations = append(violations, utils.FieldViolation{
+			Field:       field,
+			Description: "Password has appeared in a data breach, please choose another one",
+		})
+	}
+
+	if s.reusedPassword(ctx, password, user) {
+		violations = append(violations, utils.FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf("Password must differ from your last %d passwords", s.Conf.Password.HistorySize),
+		})
+	}
+
+	return violations
+}
+
+// reusedPassword reports whether the password matches the current or one of the
+// remembered passwords of the user, always false if the history is disabled
+func (s *Server) reusedPassword(ctx context.Context, password string, user models.User) bool {
+	if s.Conf.Password.HistorySize <= 0 || user.ID == uuid.Nil {
+		return false
+	}
+
+	history, err := s.R.Users().PasswordHistory(ctx, user.ID, s.Conf.Password.HistorySize)
+
+	if err != nil {
+		defer s.log().Error(err.Error())
+	}
+
+	_, span := tracing.Tracer.Start(ctx, "password.history")
+	defer span.End()
+
+	hasher := s.hasher()
+	if user.Password != "" && hasher.Verify(password, user.Password) {
+		return true
+	}
+	for _, previous := range history {
+		if hasher.Verify(password, previous.Password) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updatePassword replaces the password hash of the user and remembers the previous
+// one, only the configured number of previous hashes is kept
+func (s *Server) updatePassword(ctx context.Context, user *models.User, hash string) error {
+	return s.R.Users().UpdatePassword(ctx, user, hash, s.Conf.Password.HistorySize)
+}
+
+// verifyPassword checks the password against the stored hash. Passwords are hashed
+// in their normalized form, hashes from before the normalization are checked as is.
+// rehash reports whether the stored hash should be replaced by a current one.
+func (s *Server) verifyPassword(ctx context.Context, password string, encoded string) (match bool, rehash bool) {
+	_, span := tracing.Tracer.Start(ctx, "password.verify")
+	defer span.End()
+
+	hasher := s.hasher()
+	normalized := utils.NormalizePassword(password)
+
+	if hasher.Verify(normalized, encoded) {
+		return true, hasher.NeedsRehash(encoded)
+	}
+
+	if normalized != password && hasher.Verify(password, encoded) {
+		return true, true
+	}
+
+	return false, false
+}
+
+func toPbViolations(violations []utils.FieldViolation) []*pb.FieldViolation {
+	var resViolations []*pb.FieldViolation
+	for _, v := range violations {
+		resViolations = append(resViolations, &pb.FieldViolation{Field: v.Field, Description: v.Description})
+	}
+	return resViolations
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetPassword(t *testing.T, s *Server, user models.User, password string) *pb.ResetPasswordResponse {
+	token, err := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, Role: user.Role}, "")
+	require.NoError(t, err)
+
+	res, err := s.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Token: token, Password: password, ConfirmPassword: password})
+	require.NoError(t, err)
+	return res
+}
+
+func TestResetPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	s.Conf.Password.HistorySize = 2
+
+	hash, _ := s.hashPassword(ctx, "violet meadow one")
+	user := models.User{Email: "ada@example.com", Password: hash, Confirmed: true}
+	require.NoError(t, s.R.Users().Create(ctx, &user))
+
+	for _, password := range []string{"violet meadow two", "violet meadow three", "violet meadow four"} {
+		assert.Equal(t, int64(http.StatusOK), resetPassword(t, s, user, password).Status)
+	}
+
+	// only the configured number of previous hashes is kept
+	history, _ := s.R.Users().PasswordHistory(ctx, user.ID, 10)
+	assert.Len(t, history, 2)
+
+	// the current password and the last ones are refused
+	for _, password := range []string{"violet meadow four", "violet meadow three", "violet meadow two"} {
+		res := resetPassword(t, s, user, password)
+		assert.Equal(t, int64(http.StatusBadRequest), res.Status)
+		require.Len(t, res.Violations, 1)
+		assert.Equal(t, "Password must differ from your last 2 passwords", res.Violations[0].Description)
+	}
+
+	// the oldest one dropped out of the history
+	assert.Equal(t, int64(http.StatusOK), resetPassword(t, s, user, "violet meadow one").Status)
+}
+
+func TestResetPasswordHistoryDisabled(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+
+	hash, _ := s.hashPassword(ctx, "violet meadow one")
+	user := models.User{Email: "ada@example.com", Password: hash, Confirmed: true}
+	require.NoError(t, s.R.Users().Create(ctx, &user))
+
+	assert.Equal(t, int64(http.StatusOK), resetPassword(t, s, user, "violet meadow two").Status)
+	assert.Equal(t, int64(http.StatusOK), resetPassword(t, s, user, "violet meadow one").Status)
+	assert.Equal(t, int64(http.StatusOK), resetPassword(t, s, user, "violet meadow one").Status)
+
+	history, _ := s.R.Users().PasswordHistory(ctx, user.ID, 10)
+	assert.Empty(t, history)
+}
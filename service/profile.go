@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"golang.org/x/text/language"
+)
+
+// profileColumns maps the updatable pb.User fields to their column
+var profileColumns = map[string]string{
+	"FirstName":   "first_name",
+	"LastName":    "last_name",
+	"DisplayName": "display_name",
+	"AvatarURL":   "avatar_url",
+	"TimeZone":    "time_zone",
+	"Language":    "language",
+}
+
+func toPbUser(u models.User) *pb.User {
+	return &pb.User{
+		ID:          u.ID.String(),
+		Name:        fmt.Sprintf("%s %s", u.FirstName, u.LastName),
+		Role:        u.Role,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
+		TimeZone:    u.TimeZone,
+		Language:    u.Language,
+	}
+}
+
+// applyProfileField validates a single profile field and sets it on the user
+func applyProfileField(user *models.User, path string, profile *pb.User) error {
+	switch path {
+	case "FirstName", "LastName":
+		name := strings.TrimSpace(utils.Ternary(path == "FirstName", profile.FirstName, profile.LastName))
+		if name == "" {
+			return fmt.Errorf("%s must not be empty", path)
+		}
+		if utf8.RuneCountInString(name) > 255 {
+			return fmt.Errorf("%s must not exceed 255 characters", path)
+		}
+		if path == "FirstName" {
+			user.FirstName = name
+		} else {
+			user.LastName = name
+		}
+	case "DisplayName":
+		displayName := strings.TrimSpace(profile.DisplayName)
+		if utf8.RuneCountInString(displayName) > 255 {
+			return fmt.Errorf("DisplayName must not exceed 255 characters")
+		}
+		user.DisplayName = displayName
+	case "AvatarURL":
+		if profile.AvatarURL != "" {
+			u, err := url.Parse(profile.AvatarURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("AvatarURL must be an absolute http(s) url")
+			}
+			if len(profile.AvatarURL) > 2048 {
+				return fmt.Errorf("AvatarURL must not exceed 2048 characters")
+			}
+		}
+		user.AvatarURL = profile.AvatarURL
+	case "TimeZone":
+		if profile.TimeZone != "" {
+			if _, err := time.LoadLocation(profile.TimeZone); err != nil || profile.TimeZone == "Local" {
+				return fmt.Errorf("TimeZone must be an IANA time zone such as Europe/Zurich")
+			}
+		}
+		user.TimeZone = profile.TimeZone
+	case "Language":
+		user.Language = ""
+		if profile.Language != "" {
+			tag, err := language.Parse(profile.Language)
+			if err != nil {
+				return fmt.Errorf("Language must be a BCP 47 language tag such as de-CH")
+			}
+			user.Language = tag.String()
+		}
+	default:
+		return fmt.Errorf("%s cannot be updated", path)
+	}
+	return nil
+}
+
+func (s *Server) GetMe(ctx context.Context, req *pb.GetMeRequest) (res *pb.GetMeResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditGetMe)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var user models.User
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.GetMeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, userID); err != nil {
+		return &pb.GetMeResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	return &pb.GetMeResponse{
+		Status: http.StatusOK,
+		User:   toPbUser(user),
+		Email:  user.Email,
+	}, nil
+}
+
+func (s *Server) UpdateMe(ctx context.Context, req *pb.UpdateMeRequest) (res *pb.UpdateMeResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditUpdateMe)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var user models.User
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.UpdateMeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	if req.UpdateMask == nil || len(req.UpdateMask.Paths) == 0 || req.User == nil {
+		return &pb.UpdateMeResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Update mask and user must be provided",
+		}, nil
+	}
+
+	if user, err = s.R.Users().FindByID(ctx, userID); err != nil {
+		return &pb.UpdateMeResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such user",
+		}, nil
+	}
+
+	auditUser(event, user.ID)
+
+	var columns []string
+	var descriptions []string
+	var violations []utils.FieldViolation
+	for _, path := range req.UpdateMask.Paths {
+		if err := applyProfileField(&user, path, req.User); err != nil {
+			descriptions = append(descriptions, err.Error())
+			violations = append(violations, utils.FieldViolation{Field: path, Description: err.Error()})
+			continue
+		}
+		columns = append(columns, profileColumns[path])
+	}
+
+	if len(violations) > 0 {
+		return &pb.UpdateMeResponse{
+			Status:     http.StatusBadRequest,
+			Error:      strings.Join(descriptions, "; "),
+			Violations: toPbViolations(violations),
+		}, nil
+	}
+
+	if errUpdate := s.R.Users().Update(ctx, &user, columns...); errUpdate != nil {
+		defer s.log().Error(errUpdate.Error())
+		return &pb.UpdateMeResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "User could not be updated",
+		}, nil
+	}
+
+	return &pb.UpdateMeResponse{
+		Status: http.StatusOK,
+		User:   toPbUser(user),
+	}, nil
+}
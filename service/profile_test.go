@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestUpdateMe(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	res, _ := s.UpdateMe(ctx, &pb.UpdateMeRequest{UserID: user.ID.String(), User: &pb.User{FirstName: "Augusta"}})
+	assert.Equal(t, int64(http.StatusBadRequest), res.Status)
+	assert.Equal(t, "Update mask and user must be provided", res.Error)
+
+	// only the fields of the mask are applied
+	res, _ = s.UpdateMe(ctx, &pb.UpdateMeRequest{
+		UserID:     user.ID.String(),
+		User:       &pb.User{FirstName: "Augusta", DisplayName: "  Countess  ", TimeZone: "Europe/London", Language: "en-gb"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"DisplayName", "TimeZone", "Language"}},
+	})
+	require.Equal(t, int64(http.StatusOK), res.Status)
+	assert.Equal(t, "Countess", res.User.DisplayName)
+	assert.Equal(t, "en-GB", res.User.Language)
+
+	found, _ := s.R.Users().FindByID(ctx, user.ID)
+	assert.Equal(t, "Ada", found.FirstName)
+	assert.Equal(t, "Countess", found.DisplayName)
+	assert.Equal(t, "Europe/London", found.TimeZone)
+	assert.Equal(t, "en-GB", found.Language)
+
+	me, _ := s.GetMe(ctx, &pb.GetMeRequest{UserID: user.ID.String()})
+	assert.Equal(t, "Countess", me.User.DisplayName)
+	assert.Equal(t, "ada@example.com", me.Email)
+}
+
+func TestUpdateMeViolations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	for _, test := range []struct {
+		path        string
+		profile     *pb.User
+		description string
+	}{
+		{"FirstName", &pb.User{FirstName: "   "}, "FirstName must not be empty"},
+		{"TimeZone", &pb.User{TimeZone: "Middle/Earth"}, "TimeZone must be an IANA time zone such as Europe/Zurich"},
+		{"TimeZone", &pb.User{TimeZone: "Local"}, "TimeZone must be an IANA time zone such as Europe/Zurich"},
+		{"Language", &pb.User{Language: "not a language"}, "Language must be a BCP 47 language tag such as de-CH"},
+		{"AvatarURL", &pb.User{AvatarURL: "javascript:alert(1)"}, "AvatarURL must be an absolute http(s) url"},
+		{"AvatarURL", &pb.User{AvatarURL: "/avatar.png"}, "AvatarURL must be an absolute http(s) url"},
+		{"Email", &pb.User{}, "Email cannot be updated"},
+	} {
+		res, _ := s.UpdateMe(ctx, &pb.UpdateMeRequest{UserID: user.ID.String(), User: test.profile, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{test.path}}})
+		assert.Equal(t, int64(http.StatusBadRequest), res.Status, test.path)
+		require.Len(t, res.Violations, 1, test.path)
+		assert.Equal(t, test.path, res.Violations[0].Field)
+		assert.Equal(t, test.description, res.Violations[0].Description)
+	}
+
+	// a violation leaves the valid fields of the same request unapplied
+	res, _ := s.UpdateMe(ctx, &pb.UpdateMeRequest{
+		UserID:     user.ID.String(),
+		User:       &pb.User{DisplayName: "Countess", AvatarURL: "ftp://example.com/ada.png"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"DisplayName", "AvatarURL"}},
+	})
+	assert.Equal(t, int64(http.StatusBadRequest), res.Status)
+	found, _ := s.R.Users().FindByID(ctx, user.ID)
+	assert.Empty(t, found.DisplayName)
+	assert.Empty(t, found.AvatarURL)
+
+	res, _ = s.UpdateMe(ctx, &pb.UpdateMeRequest{
+		UserID:     user.ID.String(),
+		User:       &pb.User{AvatarURL: "https://example.com/ada.png"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"AvatarURL"}},
+	})
+	assert.Equal(t, int64(http.StatusOK), res.Status)
+	assert.Equal(t, "https://example.com/ada.png", res.User.AvatarURL)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+)
+
+var errSessionRevoked = errors.New("Session has been revoked")
+var errSessionExpired = errors.New("Session has expired")
+
+// sessionLifetime is the lifetime of the refresh token handed out with a session
+func (s *Server) sessionLifetime() time.Duration {
+	hours := utils.Ternary(s.Conf.API.RefreshTokenExpires > 0, s.Conf.API.RefreshTokenExpires, s.Jwt.ExpirationHours)
+	return time.Duration(utils.Ternary(hours > 0, hours, 24*365)) * time.Hour
+}
+
+// createSession starts a session for the user on the device described by the login audit event
+func (s *Server) createSession(ctx context.Context, userID uuid.UUID, event *models.AuditEvent, fingerprint string) (*models.Session, error) {
+	session := models.Session{
+		UserID:      userID,
+		Fingerprint: fingerprint,
+		Device:      event.UserAgent,
+		ClientIP:    event.ClientIP,
+		ExpiresAt:   time.Now().Local().Add(s.sessionLifetime()),
+	}
+
+	if err := s.R.Sessions().Create(ctx, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// activeSession returns the session a token was issued for, unless it has been revoked or has expired
+func (s *Server) activeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) (*models.Session, error) {
+	session, err := s.R.Sessions().FindByID(ctx, sessionID, userID)
+
+	if err != nil {
+		return nil, errSessionRevoked
+	}
+
+	if session.RevokedAt != nil {
+		return nil, errSessionRevoked
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		return nil, errSessionExpired
+	}
+
+	return &session, nil
+}
+
+// revokeSessions revokes every active session of the user
+func (s *Server) revokeSessions(ctx context.Context, userID uuid.UUID) error {
+	return s.R.Sessions().RevokeAll(ctx, userID)
+}
+
+func toPbSession(session models.Session, current bool) *pb.Session {
+	return &pb.Session{
+		ID:         session.ID.String(),
+		Device:     session.Device,
+		ClientIP:   session.ClientIP,
+		CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+		LastUsedAt: session.LastUsedAt.Format(time.RFC3339),
+		Current:    current,
+		ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+func (s *Server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (res *pb.ListSessionsResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditListSessions)
+	defer func() { s.audit(ctx, event, res) }()
+
+	var sessions []models.Session
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.ListSessionsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	auditUser(event, userID)
+
+	if sessions, err = s.R.Sessions().ListActive(ctx, userID); err != nil {
+		defer s.log().Error(err.Error())
+		return &pb.ListSessionsResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Listing sessions failed",
+		}, nil
+	}
+
+	var resSessions []*pb.Session
+	for _, session := range sessions {
+		resSessions = append(resSessions, toPbSession(session, session.ID.String() == req.SessionID))
+	}
+
+	return &pb.ListSessionsResponse{
+		Status:   http.StatusOK,
+		Sessions: resSessions,
+	}, nil
+}
+
+func (s *Server) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (res *pb.RevokeSessionResponse, err error) {
+	event := s.newAuditEvent(ctx, models.AuditRevokeSession)
+	defer func() { s.audit(ctx, event, res) }()
+
+	userID, err := uuid.Parse(req.UserID)
+
+	if err != nil {
+		return &pb.RevokeSessionResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user id",
+		}, nil
+	}
+
+	auditUser(event, userID)
+
+	sessionID, err := uuid.Parse(req.SessionID)
+
+	if err != nil {
+		return &pb.RevokeSessionResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid session id",
+		}, nil
+	}
+
+	session, err := s.activeSession(ctx, userID, sessionID)
+
+	if err != nil {
+		return &pb.RevokeSessionResponse{
+			Status: http.StatusNotFound,
+			Error:  "No such session",
+		}, nil
+	}
+
+	if errRevoke := s.R.Sessions().Revoke(ctx, session); errRevoke != nil {
+		defer s.log().Error(errRevoke.Error())
+		return &pb.RevokeSessionResponse{
+			Status: http.StatusInternalServerError,
+			Error:  "Session could not be revoked",
+		}, nil
+	}
+
+	return &pb.RevokeSessionResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// PruneSessions removes the expired sessions and returns their number.
+func (s *Server) PruneSessions(ctx context.Context) (int, error) {
+	deleted, err := s.R.Sessions().DeleteExpired(ctx, time.Now().Local())
+	return int(deleted), err
+}
+
+// RunSessionPrune prunes expired sessions every interval until ctx is done.
+func (s *Server) RunSessionPrune(ctx context.Context, interval time.Duration) {
+	s.runEvery(ctx, interval, "Pruning sessions", s.PruneSessions)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeSessionEndsTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+	other := createConfirmedUser(t, s, "grace@example.com")
+
+	first, _ := s.Login(ctx, &pb.LoginRequest{Email: "ada@example.com", Password: "correct horse battery staple"})
+	second, _ := s.Login(ctx, &pb.LoginRequest{Email: "ada@example.com", Password: "correct horse battery staple"})
+	require.Equal(t, int64(http.StatusOK), first.Status)
+
+	refreshed, _ := s.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	assert.Equal(t, int64(http.StatusOK), refreshed.Status)
+
+	validated, _ := s.Validate(ctx, &pb.ValidateRequest{Token: first.Token, TokenType: "ACCESS_TOKEN"})
+	require.Equal(t, int64(http.StatusOK), validated.Status)
+	sessionID := validated.SessionId
+
+	// sessions can only be revoked by their user
+	revoked, _ := s.RevokeSession(ctx, &pb.RevokeSessionRequest{UserID: other.ID.String(), SessionID: sessionID})
+	assert.Equal(t, int64(http.StatusNotFound), revoked.Status)
+
+	revoked, _ = s.RevokeSession(ctx, &pb.RevokeSessionRequest{UserID: user.ID.String(), SessionID: sessionID})
+	assert.Equal(t, int64(http.StatusOK), revoked.Status)
+
+	refreshed, _ = s.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	assert.Equal(t, int64(http.StatusUnauthorized), refreshed.Status)
+	assert.Empty(t, refreshed.Token)
+	validated, _ = s.Validate(ctx, &pb.ValidateRequest{Token: first.Token, TokenType: "ACCESS_TOKEN"})
+	assert.Equal(t, int64(http.StatusUnauthorized), validated.Status)
+
+	// the other session of the user is left alone
+	refreshed, _ = s.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: second.RefreshToken})
+	assert.Equal(t, int64(http.StatusOK), refreshed.Status)
+}
+
+func TestExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	user := createConfirmedUser(t, s, "ada@example.com")
+
+	session := models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, s.R.Sessions().Create(ctx, &session))
+	refreshToken, _ := s.Jwt.GenerateToken(utils.JwtData{Id: user.ID, Email: user.Email, SessionId: session.ID}, "REFRESH_TOKEN")
+
+	refreshed, _ := s.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: refreshToken})
+	assert.Equal(t, int64(http.StatusUnauthorized), refreshed.Status)
+
+	sessions, _ := s.ListSessions(ctx, &pb.ListSessionsRequest{UserID: user.ID.String()})
+	assert.Empty(t, sessions.Sessions)
+
+	pruned, err := s.PruneSessions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, pruned)
+}
@@ -1,68 +1,192 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/hiltpold/lakelandcup-auth-service/conf"
-	"github.com/hiltpold/lakelandcup-auth-service/models"
-	"github.com/sirupsen/logrus"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
+	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-type Repository struct {
-	DB *gorm.DB
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+// The database drivers, named like the gorm dialects
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// driver returns the configured database driver, postgres by default
+func driver(c *conf.PostgresConfiguration) string {
+	return utils.Ternary(c.Driver != "", strings.ToLower(c.Driver), DriverPostgres)
+}
+
+// quoteIdentifier quotes a database, schema or table name for use in SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
 }
 
-func openDb(c *conf.PostgresConfiguration, gormConfig *gorm.Config, database string) *gorm.DB {
-	connectionString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?search_path=%s", c.User, c.Password, c.Host, c.Port, database, c.AppDatabaseSchema)
-	db, err := gorm.Open(postgres.Open(connectionString), gormConfig)
+func connectionString(c *conf.PostgresConfiguration, database string) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, c.Port),
+		Path:     "/" + database,
+		RawQuery: url.Values{"search_path": {c.AppDatabaseSchema}}.Encode(),
+	}
+	return u.String()
+}
+
+// openDb connects to the database, retrying with exponential backoff while Postgres
+// is unavailable until the connect timeout has passed
+func openDb(ctx context.Context, c *conf.PostgresConfiguration, database string, log *zap.Logger) (*gorm.DB, error) {
+	connectionString := connectionString(c, database)
+	timeout := time.Duration(utils.Ternary(c.ConnectTimeout > 0, c.ConnectTimeout, 60)) * time.Second
+	deadline := time.Now().Add(timeout)
+
+	for backoff := initialBackoff; ; backoff = utils.Ternary(2*backoff < maxBackoff, 2*backoff, maxBackoff) {
+		db, err := gorm.Open(postgres.Open(connectionString), &gorm.Config{Logger: gormLogger{log}})
+		if err == nil {
+			usePlugins(db, log)
+			log.Info(fmt.Sprintf("Connected to database %s", database))
+			return db, nil
+		}
+		// gorm opens the pool before pinging it, the pool of a failed attempt is closed
+		if db != nil {
+			if sqlDB, errDB := db.DB(); errDB == nil {
+				sqlDB.Close()
+			}
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("unable to connect to database '%s' given url %s: %w", database, utils.Redact(connectionString), err)
+		}
+		log.Warn(fmt.Sprintf("Database %s unavailable, retrying in %s", database, backoff), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// openSQLite opens the SQLite database at the configured path, an in-memory one by default
+func openSQLite(c *conf.PostgresConfiguration, log *zap.Logger) (*gorm.DB, error) {
+	path := utils.Ternary(c.SQLitePath != "", c.SQLitePath, ":memory:")
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: gormLogger{log}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database %s: %w", path, err)
+	}
+
+	// every connection to :memory: is a database of its own and SQLite allows a single
+	// writer anyway, so all queries share one connection
+	sqlDB, err := db.DB()
 	if err != nil {
-		logrus.Fatal(fmt.Sprintf("Unable to connect to database '%s' given url: %s", database, connectionString), err)
+		return nil, err
 	}
-	logrus.Info(fmt.Sprintf("Connected to database %s", database))
-	return db
+	sqlDB.SetMaxOpenConns(1)
+
+	usePlugins(db, log)
+	log.Info(fmt.Sprintf("Opened sqlite database %s", path))
+	return db, nil
+}
+
+func usePlugins(db *gorm.DB, log *zap.Logger) {
+	if err := db.Use(metricsPlugin{}); err != nil {
+		log.Warn("Unable to observe query metrics", zap.Error(err))
+	}
+	if err := db.Use(tracingPlugin{}); err != nil {
+		log.Warn("Unable to trace queries", zap.Error(err))
+	}
+}
+
+// Dial connects to the app database of the configured driver. A postgres database must
+// have been created before, e.g. by Init.
+func Dial(ctx context.Context, c *conf.PostgresConfiguration, log *zap.Logger) (*GormRepository, error) {
+	var db *gorm.DB
+	var err error
+
+	switch driver(c) {
+	case DriverPostgres:
+		db, err = openDb(ctx, c, c.AppDatabase, log)
+	case DriverSQLite:
+		db, err = openSQLite(c, log)
+	default:
+		return nil, fmt.Errorf("unknown database driver %s", c.Driver)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &GormRepository{db}, nil
 }
 
-func Dial(c *conf.PostgresConfiguration) Repository {
-	gormDefaultConfig := &gorm.Config{}
-	gormAppConfig := &gorm.Config{}
+// Init creates the app database and schema if they do not exist yet. It needs the
+// CREATEDB privilege and is meant for development setups, production databases are
+// provisioned up front. SQLite creates its database when opening it, so there is
+// nothing to do.
+func Init(ctx context.Context, c *conf.PostgresConfiguration, log *zap.Logger) error {
+	switch driver(c) {
+	case DriverSQLite:
+		log.Info("SQLite needs no database setup")
+		return nil
+	case DriverPostgres:
+	default:
+		return fmt.Errorf("unknown database driver %s", c.Driver)
+	}
 
 	// connect to default database
-	logrus.Info(fmt.Sprintf("Use default database %s for initialization", c.DefaultDatabase))
-	defaultDb := openDb(c, gormDefaultConfig, c.DefaultDatabase)
+	log.Info(fmt.Sprintf("Use default database %s for initialization", c.DefaultDatabase))
+	defaultDb, err := openDb(ctx, c, c.DefaultDatabase, log)
+	if err != nil {
+		return err
+	}
+	defer (&GormRepository{defaultDb}).Close()
 
 	// check if database exists and create it if necessary
 	var dbexists bool
-	dbSQL := fmt.Sprintf("SELECT EXISTS (SELECT FROM pg_database WHERE datname = '%s') AS dbexists;", c.AppDatabase)
-	defaultDb.Raw(dbSQL).Row().Scan(&dbexists)
+	if err := defaultDb.Raw("SELECT EXISTS (SELECT FROM pg_database WHERE datname = ?)", c.AppDatabase).Row().Scan(&dbexists); err != nil {
+		return fmt.Errorf("unable to look up database %s: %w", c.AppDatabase, err)
+	}
 	if !dbexists {
-		logrus.Info(fmt.Sprintf("Created database %s", c.AppDatabase))
-		db := defaultDb.Exec(fmt.Sprintf("CREATE DATABASE %s;", c.AppDatabase))
-		if db.Error != nil {
-			logrus.Fatal("Unable to create app database: ", db.Error)
+		// identifiers can not be passed as parameters, they are quoted instead
+		if db := defaultDb.Exec("CREATE DATABASE " + quoteIdentifier(c.AppDatabase)); db.Error != nil {
+			return fmt.Errorf("unable to create database %s: %w", c.AppDatabase, db.Error)
 		}
+		log.Info(fmt.Sprintf("Created database %s", c.AppDatabase))
 	}
 
 	// connect to app databse
-	appDb := openDb(c, gormAppConfig, c.AppDatabase)
+	appDb, err := openDb(ctx, c, c.AppDatabase, log)
+	if err != nil {
+		return err
+	}
+	defer (&GormRepository{appDb}).Close()
 
-	// check if schema exists and create it if necessary
-	var schemaexists bool
-	schemaSQL := fmt.Sprintf("SELECT EXISTS(SELECT FROM pg_namespace WHERE nspname = '%s') AS schemaexisits;", c.AppDatabaseSchema)
-	appDb.Raw(schemaSQL).Row().Scan(&schemaexists)
 	// create app specfic schema, if not already existing
+	var schemaexists bool
+	if err := appDb.Raw("SELECT EXISTS (SELECT FROM pg_namespace WHERE nspname = ?)", c.AppDatabaseSchema).Row().Scan(&schemaexists); err != nil {
+		return fmt.Errorf("unable to look up schema %s: %w", c.AppDatabaseSchema, err)
+	}
 	if !schemaexists {
-		// create service specific schema
-		db := appDb.Exec(fmt.Sprintf("CREATE SCHEMA %s;", c.AppDatabaseSchema))
-		if db.Error != nil {
-			logrus.Fatal(fmt.Sprintf("Unable to create database schema %s", c.AppDatabaseSchema), db.Error)
+		if db := appDb.Exec("CREATE SCHEMA " + quoteIdentifier(c.AppDatabaseSchema)); db.Error != nil {
+			return fmt.Errorf("unable to create database schema %s: %w", c.AppDatabaseSchema, db.Error)
 		}
-		logrus.Info(fmt.Sprintf("Created database schema %s", c.AppDatabaseSchema))
+		log.Info(fmt.Sprintf("Created database schema %s", c.AppDatabaseSchema))
 	}
 
-	// migrate table
-	appDb.AutoMigrate(&models.User{})
-
-	return Repository{appDb}
+	return nil
 }
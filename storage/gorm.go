@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// GormRepository stores everything in the database behind DB
+type GormRepository struct {
+	DB *gorm.DB
+}
+
+func (r *GormRepository) Users() UserRepository                 { return gormUsers{r.DB} }
+func (r *GormRepository) EmailChanges() EmailChangeRepository   { return gormEmailChanges{r.DB} }
+func (r *GormRepository) Sessions() SessionRepository           { return gormSessions{r.DB} }
+func (r *GormRepository) LoginAttempts() LoginAttemptRepository { return gormLoginAttempts{r.DB} }
+func (r *GormRepository) AuditEvents() AuditEventRepository     { return gormAuditEvents{r.DB} }
+
+func (r *GormRepository) Ping(ctx context.Context) error {
+	sqlDB, err := r.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close closes the connection pool of the database
+func (r *GormRepository) Close() error {
+	sqlDB, err := r.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// first loads the first record matching the query into dest and reports a missing one as ErrNotFound
+func first(query *gorm.DB, dest interface{}) error {
+	err := query.First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicateEmail reports a violation of the unique email indexes as ErrDuplicateEmail,
+// postgres names the violated constraint and SQLite its column or index in the message
+func duplicateEmail(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code == "23505" && strings.Contains(pgErr.ConstraintName, "email") {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	if message := err.Error(); strings.Contains(message, "UNIQUE constraint failed") && strings.Contains(message, "email") {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r gormUsers) FindByID(ctx context.Context, id uuid.UUID) (user models.User, err error) {
+	err = first(r.db.WithContext(ctx).Where("id = ?", id), &user)
+	return user, err
+}
+
+func (r gormUsers) FindByEmail(ctx context.Context, email string) (user models.User, err error) {
+	err = first(r.db.WithContext(ctx).Where("lower(email) = ?", strings.ToLower(email)), &user)
+	return user, err
+}
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	return duplicateEmail(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r gormUsers) Update(ctx context.Context, user *models.User, columns ...string) error {
+	return duplicateEmail(r.db.WithContext(ctx).Model(user).Select(append(columns, "updated_at")).Updates(user).Error)
+}
+
+func (r gormUsers) SetConfirmed(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Model(user).Update("confirmed", true).Error
+}
+
+func (r gormUsers) UpdatePassword(ctx context.Context, user *models.User, hash string, historySize int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if historySize > 0 && user.Password != "" {
+			if result := tx.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		var keep []uuid.UUID
+		if historySize > 0 {
+			if result := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Order("created_at desc").Limit(historySize).Pluck("id", &keep); result.Error != nil {
+				return result.Error
+			}
+		}
+		prune := tx.Where("user_id = ?", user.ID)
+		if len(keep) > 0 {
+			prune = prune.Where("id NOT IN ?", keep)
+		}
+		if result := prune.Delete(&models.PasswordHistory{}); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(user).Update("password", hash).Error
+	})
+}
+
+func (r gormUsers) PasswordHistory(ctx context.Context, userID uuid.UUID, limit int) (history []models.PasswordHistory, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&history).Error
+	return history, err
+}
+
+func (r gormUsers) ListConfirmed(ctx context.Context) (users []models.User, err error) {
+	err = r.db.WithContext(ctx).Where(&models.User{Confirmed: true}).Where("delete_after IS NULL").Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) ListDeletionDue(ctx context.Context, at time.Time) (users []models.User, err error) {
+	err = r.db.WithContext(ctx).Where("delete_after <= ?", at).Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) Purge(ctx context.Context, user *models.User, anonymize bool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("user_id = ?", user.ID).Delete(&models.EmailChange{}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("user_id = ?", user.ID).Delete(&models.LoginAttempt{}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("user_id = ?", user.ID).Delete(&models.Session{}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{}); result.Error != nil {
+			return result.Error
+		}
+		if anonymize {
+			return tx.Model(user).Updates(map[string]interface{}{
+				"first_name":   "Deleted",
+				"last_name":    "User",
+				"email":        fmt.Sprintf("deleted-%s@invalid", user.ID),
+				"password":     "",
+				"confirmed":    false,
+				"display_name": "",
+				"avatar_url":   "",
+				"time_zone":    "",
+				"language":     "",
+				"delete_after": nil,
+			}).Error
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+type gormEmailChanges struct {
+	db *gorm.DB
+}
+
+func (r gormEmailChanges) FindByID(ctx context.Context, id uuid.UUID) (change models.EmailChange, err error) {
+	err = first(r.db.WithContext(ctx).Where("id = ?", id), &change)
+	return change, err
+}
+
+func (r gormEmailChanges) Create(ctx context.Context, change *models.EmailChange) error {
+	return r.db.WithContext(ctx).Create(change).Error
+}
+
+func (r gormEmailChanges) Apply(ctx context.Context, change *models.EmailChange, user *models.User, email string) error {
+	return duplicateEmail(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if update := tx.Model(user).Update("email", email); update.Error != nil {
+			return update.Error
+		}
+		return tx.Model(change).Select("confirmed_at", "undo_expires_at", "reverted_at", "updated_at").Updates(change).Error
+	}))
+}
+
+func (r gormEmailChanges) ListByUser(ctx context.Context, userID uuid.UUID) (changes []models.EmailChange, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&changes).Error
+	return changes, err
+}
+
+type gormSessions struct {
+	db *gorm.DB
+}
+
+func (r gormSessions) FindByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (session models.Session, err error) {
+	err = first(r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID), &session)
+	return session, err
+}
+
+func (r gormSessions) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r gormSessions) Touch(ctx context.Context, session *models.Session, clientIP string) error {
+	return r.db.WithContext(ctx).Model(session).Updates(models.Session{LastUsedAt: time.Now().Local(), ClientIP: clientIP}).Error
+}
+
+func (r gormSessions) Revoke(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Model(session).Update("revoked_at", time.Now().Local()).Error
+}
+
+func (r gormSessions) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now().Local()).Error
+}
+
+func (r gormSessions) ListActive(ctx context.Context, userID uuid.UUID) (sessions []models.Session, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now().Local()).Order("last_used_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r gormSessions) ListByUser(ctx context.Context, userID uuid.UUID) (sessions []models.Session, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r gormSessions) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+type gormLoginAttempts struct {
+	db *gorm.DB
+}
+
+func (r gormLoginAttempts) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	return r.db.WithContext(ctx).Create(attempt).Error
+}
+
+func (r gormLoginAttempts) CountSuccessful(ctx context.Context, userID uuid.UUID, fingerprint string) (count int64, err error) {
+	query := r.db.WithContext(ctx).Model(&models.LoginAttempt{}).Where("user_id = ? AND success = ?", userID, true)
+	if fingerprint != "" {
+		query = query.Where("fingerprint = ?", fingerprint)
+	}
+	err = query.Count(&count).Error
+	return count, err
+}
+
+func (r gormLoginAttempts) Recent(ctx context.Context, userID uuid.UUID, limit int) (attempts []models.LoginAttempt, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+func (r gormLoginAttempts) ListByUser(ctx context.Context, userID uuid.UUID) (attempts []models.LoginAttempt, err error) {
+	err = r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&attempts).Error
+	return attempts, err
+}
+
+type gormAuditEvents struct {
+	db *gorm.DB
+}
+
+func (r gormAuditEvents) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r gormAuditEvents) List(ctx context.Context, filter AuditFilter) (events []models.AuditEvent, err error) {
+	query := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.SubjectID != nil {
+		query = query.Where("subject_id = ?", *filter.SubjectID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	err = query.Order("created_at desc").Offset(filter.Offset).Find(&events).Error
+	return events, err
+}
+
+func (r gormAuditEvents) ListByUser(ctx context.Context, userID uuid.UUID) (events []models.AuditEvent, err error) {
+	err = r.db.WithContext(ctx).Where("subject_id = ? OR actor_id = ?", userID, userID).Order("created_at").Find(&events).Error
+	return events, err
+}
+
+func (r gormAuditEvents) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("created_at < ?", before).Delete(&models.AuditEvent{})
+	return result.RowsAffected, result.Error
+}
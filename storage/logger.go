@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger writes the logs of GORM to the service logger. Statements are only logged
+// at debug level since their values may contain personal data and password hashes.
+type gormLogger struct {
+	log *zap.Logger
+}
+
+func (l gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.log.Info(fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.log.Warn(fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.log.Error(fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		l.log.Error("Query failed", zap.Error(err), zap.Duration("elapsed", elapsed))
+	case elapsed > slowQueryThreshold:
+		l.log.Warn("Slow query", zap.Duration("elapsed", elapsed))
+	}
+
+	if ce := l.log.Check(zap.DebugLevel, "Query"); ce != nil {
+		sql, rows := fc()
+		ce.Write(zap.String("sql", sql), zap.Int64("rows", rows), zap.Duration("elapsed", elapsed))
+	}
+}
@@ -0,0 +1,542 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"gorm.io/gorm/schema"
+)
+
+// MemoryRepository keeps everything in memory. It is meant for tests and behaves like
+// the GORM implementation, including the case insensitive uniqueness of emails.
+type MemoryRepository struct {
+	mu           sync.Mutex
+	users        map[uuid.UUID]models.User
+	emailChanges map[uuid.UUID]models.EmailChange
+	sessions     map[uuid.UUID]models.Session
+	logins       []models.LoginAttempt
+	auditEvents  []models.AuditEvent
+	history      []models.PasswordHistory
+}
+
+// NewMemoryRepository returns an empty in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:        map[uuid.UUID]models.User{},
+		emailChanges: map[uuid.UUID]models.EmailChange{},
+		sessions:     map[uuid.UUID]models.Session{},
+	}
+}
+
+func (r *MemoryRepository) Users() UserRepository                 { return memoryUsers{r} }
+func (r *MemoryRepository) EmailChanges() EmailChangeRepository   { return memoryEmailChanges{r} }
+func (r *MemoryRepository) Sessions() SessionRepository           { return memorySessions{r} }
+func (r *MemoryRepository) LoginAttempts() LoginAttemptRepository { return memoryLoginAttempts{r} }
+func (r *MemoryRepository) AuditEvents() AuditEventRepository     { return memoryAuditEvents{r} }
+
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (r *MemoryRepository) Close() error {
+	return nil
+}
+
+// emailTaken reports whether another user than id has the email, regardless of case
+func (r *MemoryRepository) emailTaken(email string, id uuid.UUID) bool {
+	for _, user := range r.users {
+		if user.ID != id && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyColumns copies the fields of the given column names from src to dst
+func copyColumns(dst interface{}, src interface{}, columns []string) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	naming := schema.NamingStrategy{}
+	for _, column := range columns {
+		for i := 0; i < s.NumField(); i++ {
+			if naming.ColumnName("", s.Type().Field(i).Name) == column {
+				d.Field(i).Set(s.Field(i))
+			}
+		}
+	}
+}
+
+type memoryUsers struct {
+	r *MemoryRepository
+}
+
+func (m memoryUsers) FindByID(ctx context.Context, id uuid.UUID) (models.User, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	user, ok := m.r.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m memoryUsers) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	for _, user := range m.r.users {
+		if strings.ToLower(user.Email) == strings.ToLower(email) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (m memoryUsers) Create(ctx context.Context, user *models.User) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	if m.r.emailTaken(user.Email, uuid.Nil) {
+		return ErrDuplicateEmail
+	}
+	user.BeforeCreate(nil)
+	m.r.users[user.ID] = *user
+	return nil
+}
+
+func (m memoryUsers) Update(ctx context.Context, user *models.User, columns ...string) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	stored, ok := m.r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, column := range columns {
+		if column == "email" && m.r.emailTaken(user.Email, user.ID) {
+			return ErrDuplicateEmail
+		}
+	}
+
+	user.BeforeUpdate(nil)
+	copyColumns(&stored, user, append(columns, "updated_at"))
+	m.r.users[user.ID] = stored
+	return nil
+}
+
+func (m memoryUsers) SetConfirmed(ctx context.Context, user *models.User) error {
+	user.Confirmed = true
+	return m.Update(ctx, user, "confirmed")
+}
+
+func (m memoryUsers) UpdatePassword(ctx context.Context, user *models.User, hash string, historySize int) error {
+	m.r.mu.Lock()
+	if historySize > 0 && user.Password != "" {
+		previous := models.PasswordHistory{UserID: user.ID, Password: user.Password}
+		previous.BeforeCreate(nil)
+		m.r.history = append(m.r.history, previous)
+	}
+
+	// keep the newest historySize hashes of the user
+	ofUser := 0
+	for _, h := range m.r.history {
+		if h.UserID == user.ID {
+			ofUser++
+		}
+	}
+	var kept []models.PasswordHistory
+	for _, h := range m.r.history {
+		if h.UserID == user.ID && ofUser > historySize {
+			ofUser--
+			continue
+		}
+		kept = append(kept, h)
+	}
+	m.r.history = kept
+	m.r.mu.Unlock()
+
+	user.Password = hash
+	return m.Update(ctx, user, "password")
+}
+
+func (m memoryUsers) PasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistory, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	var history []models.PasswordHistory
+	for i := len(m.r.history) - 1; i >= 0 && len(history) < limit; i-- {
+		if m.r.history[i].UserID == userID {
+			history = append(history, m.r.history[i])
+		}
+	}
+	return history, nil
+}
+
+func (m memoryUsers) list(match func(models.User) bool) []models.User {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	var users []models.User
+	for _, user := range m.r.users {
+		if match(user) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+	return users
+}
+
+func (m memoryUsers) ListConfirmed(ctx context.Context) ([]models.User, error) {
+	return m.list(func(user models.User) bool { return user.Confirmed && user.DeleteAfter == nil }), nil
+}
+
+func (m memoryUsers) ListDeletionDue(ctx context.Context, at time.Time) ([]models.User, error) {
+	return m.list(func(user models.User) bool { return user.DeleteAfter != nil && !user.DeleteAfter.After(at) }), nil
+}
+
+func (m memoryUsers) Purge(ctx context.Context, user *models.User, anonymize bool) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	for id, change := range m.r.emailChanges {
+		if change.UserID == user.ID {
+			delete(m.r.emailChanges, id)
+		}
+	}
+	for id, session := range m.r.sessions {
+		if session.UserID == user.ID {
+			delete(m.r.sessions, id)
+		}
+	}
+
+	logins := m.r.logins[:0]
+	for _, attempt := range m.r.logins {
+		if attempt.UserID == nil || *attempt.UserID != user.ID {
+			logins = append(logins, attempt)
+		}
+	}
+	m.r.logins = logins
+
+	history := m.r.history[:0]
+	for _, previous := range m.r.history {
+		if previous.UserID != user.ID {
+			history = append(history, previous)
+		}
+	}
+	m.r.history = history
+
+	if !anonymize {
+		delete(m.r.users, user.ID)
+		return nil
+	}
+
+	stored := m.r.users[user.ID]
+	stored.FirstName, stored.LastName = "Deleted", "User"
+	stored.Email = fmt.Sprintf("deleted-%s@invalid", user.ID)
+	stored.Password, stored.Confirmed = "", false
+	stored.DisplayName, stored.AvatarURL, stored.TimeZone, stored.Language = "", "", "", ""
+	stored.DeleteAfter = nil
+	stored.BeforeUpdate(nil)
+	m.r.users[user.ID] = stored
+	*user = stored
+	return nil
+}
+
+type memoryEmailChanges struct {
+	r *MemoryRepository
+}
+
+func (m memoryEmailChanges) FindByID(ctx context.Context, id uuid.UUID) (models.EmailChange, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	change, ok := m.r.emailChanges[id]
+	if !ok {
+		return models.EmailChange{}, ErrNotFound
+	}
+	return change, nil
+}
+
+func (m memoryEmailChanges) Create(ctx context.Context, change *models.EmailChange) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	change.BeforeCreate(nil)
+	m.r.emailChanges[change.ID] = *change
+	return nil
+}
+
+func (m memoryEmailChanges) Apply(ctx context.Context, change *models.EmailChange, user *models.User, email string) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	stored, ok := m.r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if m.r.emailTaken(email, user.ID) {
+		return ErrDuplicateEmail
+	}
+
+	user.Email = email
+	user.BeforeUpdate(nil)
+	copyColumns(&stored, user, []string{"email", "updated_at"})
+	m.r.users[user.ID] = stored
+
+	change.BeforeUpdate(nil)
+	m.r.emailChanges[change.ID] = *change
+	return nil
+}
+
+func (m memoryEmailChanges) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.EmailChange, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	changes := []models.EmailChange{}
+	for _, change := range m.r.emailChanges {
+		if change.UserID == userID {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].CreatedAt.Before(changes[j].CreatedAt) })
+	return changes, nil
+}
+
+type memorySessions struct {
+	r *MemoryRepository
+}
+
+func (m memorySessions) FindByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Session, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	session, ok := m.r.sessions[id]
+	if !ok || session.UserID != userID {
+		return models.Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (m memorySessions) Create(ctx context.Context, session *models.Session) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	session.BeforeCreate(nil)
+	m.r.sessions[session.ID] = *session
+	return nil
+}
+
+// update applies fn to the stored session and to the given copy
+func (m memorySessions) update(session *models.Session, fn func(*models.Session)) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	stored, ok := m.r.sessions[session.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&stored)
+	fn(session)
+	m.r.sessions[session.ID] = stored
+	return nil
+}
+
+func (m memorySessions) Touch(ctx context.Context, session *models.Session, clientIP string) error {
+	now := time.Now().Local()
+	return m.update(session, func(s *models.Session) {
+		s.LastUsedAt = now
+		if clientIP != "" {
+			s.ClientIP = clientIP
+		}
+	})
+}
+
+func (m memorySessions) Revoke(ctx context.Context, session *models.Session) error {
+	now := time.Now().Local()
+	return m.update(session, func(s *models.Session) { s.RevokedAt = &now })
+}
+
+func (m memorySessions) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	now := time.Now().Local()
+	for id, session := range m.r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			m.r.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (m memorySessions) list(userID uuid.UUID, active bool) []models.Session {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range m.r.sessions {
+		if session.UserID == userID && (!active || session.RevokedAt == nil && session.ExpiresAt.After(now)) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
+
+func (m memorySessions) ListActive(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	sessions := m.list(userID, true)
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (m memorySessions) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	sessions := m.list(userID, false)
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (m memorySessions) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	var deleted int64
+	for id, session := range m.r.sessions {
+		if session.ExpiresAt.Before(before) {
+			delete(m.r.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type memoryLoginAttempts struct {
+	r *MemoryRepository
+}
+
+func (m memoryLoginAttempts) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	attempt.BeforeCreate(nil)
+	m.r.logins = append(m.r.logins, *attempt)
+	return nil
+}
+
+// ofUser returns the logins of the user, oldest first
+func (m memoryLoginAttempts) ofUser(userID uuid.UUID) []models.LoginAttempt {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	attempts := []models.LoginAttempt{}
+	for _, attempt := range m.r.logins {
+		if attempt.UserID != nil && *attempt.UserID == userID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts
+}
+
+func (m memoryLoginAttempts) CountSuccessful(ctx context.Context, userID uuid.UUID, fingerprint string) (int64, error) {
+	var count int64
+	for _, attempt := range m.ofUser(userID) {
+		if attempt.Success && (fingerprint == "" || attempt.Fingerprint == fingerprint) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m memoryLoginAttempts) Recent(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	all := m.ofUser(userID)
+	for i := len(all) - 1; i >= 0 && len(attempts) < limit; i-- {
+		attempts = append(attempts, all[i])
+	}
+	return attempts, nil
+}
+
+func (m memoryLoginAttempts) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.LoginAttempt, error) {
+	return m.ofUser(userID), nil
+}
+
+type memoryAuditEvents struct {
+	r *MemoryRepository
+}
+
+func (m memoryAuditEvents) Create(ctx context.Context, event *models.AuditEvent) error {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	event.BeforeCreate(nil)
+	m.r.auditEvents = append(m.r.auditEvents, *event)
+	return nil
+}
+
+func sameID(id *uuid.UUID, other *uuid.UUID) bool {
+	return other != nil && *id == *other
+}
+
+func (m memoryAuditEvents) List(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	var events []models.AuditEvent
+	skipped := 0
+	for i := len(m.r.auditEvents) - 1; i >= 0; i-- {
+		e := m.r.auditEvents[i]
+		switch {
+		case filter.ActorID != nil && !sameID(filter.ActorID, e.ActorID),
+			filter.SubjectID != nil && !sameID(filter.SubjectID, e.SubjectID),
+			filter.Type != "" && e.Type != filter.Type,
+			filter.Outcome != "" && e.Outcome != filter.Outcome,
+			filter.Since != nil && e.CreatedAt.Before(*filter.Since),
+			filter.Until != nil && !e.CreatedAt.Before(*filter.Until):
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (m memoryAuditEvents) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.AuditEvent, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	events := []models.AuditEvent{}
+	for _, e := range m.r.auditEvents {
+		if sameID(&userID, e.ActorID) || sameID(&userID, e.SubjectID) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m memoryAuditEvents) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+
+	kept := m.r.auditEvents[:0]
+	for _, e := range m.r.auditEvents {
+		if !e.CreatedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	deleted := int64(len(m.r.auditEvents) - len(kept))
+	m.r.auditEvents = kept
+	return deleted, nil
+}
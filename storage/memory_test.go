@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryUsers(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryRepository().Users()
+
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "hash-1"}
+	assert.Nil(t, users.Create(ctx, &user))
+	assert.Equal(t, ErrDuplicateEmail, users.Create(ctx, &models.User{Email: "ADA@example.com"}))
+
+	found, err := users.FindByEmail(ctx, "Ada@Example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = users.FindByEmail(ctx, "bob@example.com")
+	assert.Equal(t, ErrNotFound, err)
+
+	// only the given columns are stored
+	found.DisplayName = "Countess"
+	found.FirstName = "Augusta"
+	assert.Nil(t, users.Update(ctx, &found, "display_name"))
+	found, _ = users.FindByID(ctx, user.ID)
+	assert.Equal(t, "Countess", found.DisplayName)
+	assert.Equal(t, "Ada", found.FirstName)
+
+	confirmed, _ := users.ListConfirmed(ctx)
+	assert.Empty(t, confirmed)
+	assert.Nil(t, users.SetConfirmed(ctx, &found))
+	confirmed, _ = users.ListConfirmed(ctx)
+	assert.Len(t, confirmed, 1)
+
+	for _, hash := range []string{"hash-2", "hash-3", "hash-4"} {
+		assert.Nil(t, users.UpdatePassword(ctx, &found, hash, 2))
+	}
+	found, _ = users.FindByID(ctx, user.ID)
+	assert.Equal(t, "hash-4", found.Password)
+	history, _ := users.PasswordHistory(ctx, user.ID, 5)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "hash-3", history[0].Password)
+	assert.Equal(t, "hash-2", history[1].Password)
+
+	deleteAfter := time.Now().Add(-time.Minute)
+	found.DeleteAfter = &deleteAfter
+	assert.Nil(t, users.Update(ctx, &found, "delete_after"))
+	due, _ := users.ListDeletionDue(ctx, time.Now())
+	assert.Len(t, due, 1)
+
+	assert.Nil(t, users.Purge(ctx, &due[0], true))
+	found, _ = users.FindByID(ctx, user.ID)
+	assert.Equal(t, "Deleted", found.FirstName)
+	assert.Nil(t, found.DeleteAfter)
+	history, _ = users.PasswordHistory(ctx, user.ID, 5)
+	assert.Empty(t, history)
+
+	assert.Nil(t, users.Purge(ctx, &found, false))
+	_, err = users.FindByID(ctx, user.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemorySessionsAndAudit(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	user := models.User{Email: "ada@example.com"}
+	assert.Nil(t, r.Users().Create(ctx, &user))
+
+	expiresAt := time.Now().Add(time.Hour)
+	first := models.Session{UserID: user.ID, ExpiresAt: expiresAt}
+	second := models.Session{UserID: user.ID, ExpiresAt: expiresAt}
+	expired := models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)}
+	assert.Nil(t, r.Sessions().Create(ctx, &first))
+	assert.Nil(t, r.Sessions().Create(ctx, &second))
+	assert.Nil(t, r.Sessions().Create(ctx, &expired))
+	assert.Nil(t, r.Sessions().Touch(ctx, &first, "10.0.0.1"))
+
+	active, _ := r.Sessions().ListActive(ctx, user.ID)
+	assert.Len(t, active, 2)
+	assert.Equal(t, first.ID, active[0].ID)
+	assert.Equal(t, "10.0.0.1", active[0].ClientIP)
+
+	assert.Nil(t, r.Sessions().Revoke(ctx, &second))
+	active, _ = r.Sessions().ListActive(ctx, user.ID)
+	assert.Len(t, active, 1)
+	assert.Nil(t, r.Sessions().RevokeAll(ctx, user.ID))
+	active, _ = r.Sessions().ListActive(ctx, user.ID)
+	assert.Empty(t, active)
+
+	deleted, _ := r.Sessions().DeleteExpired(ctx, time.Now())
+	assert.Equal(t, int64(1), deleted)
+	all, _ := r.Sessions().ListByUser(ctx, user.ID)
+	assert.Len(t, all, 2)
+
+	for _, outcome := range []string{models.AuditOutcomeSuccess, models.AuditOutcomeFailure, models.AuditOutcomeSuccess} {
+		assert.Nil(t, r.AuditEvents().Create(ctx, &models.AuditEvent{Type: models.AuditLogin, Outcome: outcome, SubjectID: &user.ID}))
+	}
+	events, _ := r.AuditEvents().List(ctx, AuditFilter{Outcome: models.AuditOutcomeSuccess, Limit: 1})
+	assert.Len(t, events, 1)
+	events, _ = r.AuditEvents().List(ctx, AuditFilter{SubjectID: &user.ID, Offset: 1})
+	assert.Len(t, events, 2)
+	events, _ = r.AuditEvents().ListByUser(ctx, user.ID)
+	assert.Len(t, events, 3)
+
+	deleted, _ = r.AuditEvents().DeleteBefore(ctx, time.Now().Add(time.Minute))
+	assert.Equal(t, int64(3), deleted)
+}
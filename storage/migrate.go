@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MigrationsDir is where the migrations of the postgres schema live in the repository
+const MigrationsDir = "storage/migrations/postgres"
+
+// SQLiteMigrationsDir holds the SQLite counterpart of every postgres migration, with the same version
+const SQLiteMigrationsDir = "storage/migrations/sqlite"
+
+// migrationLock is the postgres advisory lock held while migrating, so replicas
+// starting at the same time do not apply a migration twice
+const migrationLock = 7217834
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var addColumnIfNotExists = regexp.MustCompile(`(?im)^ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+) ([^;]*);`)
+
+// Migration is a versioned change of the schema with the SQL to apply and to revert it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether and when a migration was applied
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the table recording the applied migrations
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// loadMigrations reads the migrations from dir, every version needs an up and a down file
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		sql, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts the migrations embedded in the binary
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the embedded migrations of the database driver
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	var migrations []Migration
+	var err error
+
+	switch db.Dialector.Name() {
+	case DriverPostgres:
+		migrations, err = loadMigrations(postgresMigrations, "migrations/postgres")
+	case DriverSQLite:
+		migrations, err = loadMigrations(sqliteMigrations, "migrations/sqlite")
+	default:
+		return nil, fmt.Errorf("no migrations for database driver %s", db.Dialector.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) applied(tx *gorm.DB) (map[int64]schemaMigration, error) {
+	if err := tx.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []schemaMigration
+	if result := tx.Find(&rows); result.Error != nil {
+		return nil, result.Error
+	}
+
+	applied := map[int64]schemaMigration{}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Status lists every migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var status []MigrationStatus
+	for _, migration := range m.migrations {
+		s := MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Pending returns the migrations that are not applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// lock serializes migrations across processes for the duration of the transaction
+func lock(tx *gorm.DB) error {
+	if tx.Dialector.Name() != DriverPostgres {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error
+}
+
+// guardAddColumns rewrites ADD COLUMN IF NOT EXISTS for SQLite, which does not know it,
+// by leaving out the statements of columns the table already has
+func guardAddColumns(tx *gorm.DB, sql string) string {
+	if tx.Dialector.Name() != DriverSQLite {
+		return sql
+	}
+	return addColumnIfNotExists.ReplaceAllStringFunc(sql, func(statement string) string {
+		match := addColumnIfNotExists.FindStringSubmatch(statement)
+		if tx.Migrator().HasColumn(match[1], match[2]) {
+			return ""
+		}
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", match[1], match[2], match[3])
+	})
+}
+
+// Up applies up to steps pending migrations in order, all of them if steps is not positive.
+// Every migration runs in its own transaction and the applied ones are returned.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	for _, migration := range m.migrations {
+		if steps > 0 && len(done) == steps {
+			break
+		}
+
+		ran := false
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			applied, err := m.applied(tx)
+			if err != nil {
+				return err
+			}
+			if _, ok := applied[migration.Version]; ok {
+				return nil
+			}
+			if err := tx.Exec(guardAddColumns(tx, migration.Up)).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().Local()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+
+	return done, nil
+}
+
+// Down reverts up to steps applied migrations, newest first, and returns the reverted ones
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+
+		ran := false
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			applied, err := m.applied(tx)
+			if err != nil {
+				return err
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				return nil
+			}
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+
+	return done, nil
+}
+
+// CreateMigration writes empty up and down files for the next version to every dir, e.g.
+// MigrationsDir and SQLiteMigrationsDir so the drivers stay in step, and returns their paths
+func CreateMigration(dirs []string, name string) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`\W+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name is empty")
+	}
+
+	version := int64(1)
+	for _, dir := range dirs {
+		migrations, err := loadMigrations(os.DirFS(dir), ".")
+		if err != nil {
+			return nil, err
+		}
+		if len(migrations) > 0 && migrations[len(migrations)-1].Version >= version {
+			version = migrations[len(migrations)-1].Version + 1
+		}
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		up := filepath.Join(dir, fmt.Sprintf("%04d_%s.up.sql", version, name))
+		down := filepath.Join(dir, fmt.Sprintf("%04d_%s.down.sql", version, name))
+		if err := os.WriteFile(up, []byte(fmt.Sprintf("-- %s\n", name)), 0o644); err != nil {
+			return paths, err
+		}
+		if err := os.WriteFile(down, []byte(fmt.Sprintf("-- revert %s\n", name)), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, up, down)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(fstest.MapFS{
+		"m/0002_add_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN c text;")},
+		"m/0002_add_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"m/0001_baseline.up.sql":     {Data: []byte("CREATE TABLE t (id text);")},
+		"m/0001_baseline.down.sql":   {Data: []byte("DROP TABLE t;")},
+		"m/README.md":                {Data: []byte("ignored")},
+	}, "m")
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "baseline", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE t;", migrations[0].Down)
+	assert.Equal(t, "add_column", migrations[1].Name)
+
+	_, err = loadMigrations(fstest.MapFS{
+		"m/0001_baseline.up.sql": {Data: []byte("CREATE TABLE t (id text);")},
+	}, "m")
+	assert.NotNil(t, err)
+
+	// the embedded migrations are complete and every driver has the same versions
+	migrations, err = loadMigrations(postgresMigrations, "migrations/postgres")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, migrations)
+
+	sqliteMigrations, err := loadMigrations(sqliteMigrations, "migrations/sqlite")
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), len(sqliteMigrations))
+	for i := range sqliteMigrations {
+		assert.Equal(t, migrations[i].Version, sqliteMigrations[i].Version)
+		assert.Equal(t, migrations[i].Name, sqliteMigrations[i].Name)
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+
+	paths, err := CreateMigration([]string{dir}, "Add Users")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "0001_add_users.up.sql"), filepath.Join(dir, "0001_add_users.down.sql")}, paths)
+
+	// the version follows the newest migration of every dir
+	paths, err = CreateMigration([]string{dir, other}, "drop-column")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "0002_drop_column.up.sql"),
+		filepath.Join(dir, "0002_drop_column.down.sql"),
+		filepath.Join(other, "0002_drop_column.up.sql"),
+		filepath.Join(other, "0002_drop_column.down.sql"),
+	}, paths)
+
+	migrations, err := loadMigrations(os.DirFS(dir), ".")
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 2)
+	migrations, err = loadMigrations(os.DirFS(other), ".")
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 1)
+
+	_, err = CreateMigration([]string{dir}, "--")
+	assert.NotNil(t, err)
+}
+
+func TestUpgradeAutoMigrateSchema(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := Dial(ctx, &conf.PostgresConfiguration{Driver: DriverSQLite}, zap.NewNop())
+	require.NoError(t, err)
+	defer r.Close()
+
+	// the users table as the first AutoMigrate created it, before the profile and deletion columns
+	require.NoError(t, r.DB.Exec(`CREATE TABLE users (
+		id text PRIMARY KEY,
+		first_name varchar(255) NOT NULL,
+		last_name varchar(255) NOT NULL,
+		email varchar(255) NOT NULL UNIQUE,
+		role varchar(255),
+		confirmed boolean DEFAULT false,
+		password text,
+		created_at datetime,
+		updated_at datetime
+	)`).Error)
+	require.NoError(t, r.DB.Exec(`INSERT INTO users (id, first_name, last_name, email, confirmed, created_at)
+		VALUES ('0b6e5c1e-6b1f-4f7e-9a57-6a1f4c3c9d10', 'Ada', 'Lovelace', 'ada@example.com', true, CURRENT_TIMESTAMP)`).Error)
+
+	migrator, err := NewMigrator(r.DB)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+
+	confirmed, err := r.Users().ListConfirmed(ctx)
+	require.NoError(t, err)
+	require.Len(t, confirmed, 1)
+
+	user := confirmed[0]
+	user.DisplayName = "Countess"
+	deleteAfter := time.Now().Add(-time.Minute)
+	user.DeleteAfter = &deleteAfter
+	assert.Nil(t, r.Users().Update(ctx, &user, "display_name", "delete_after"))
+
+	due, err := r.Users().ListDeletionDue(ctx, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, "Countess", due[0].DisplayName)
+	assert.Nil(t, r.Users().Purge(ctx, &due[0], false))
+}
+
+func TestSessionsExpiresAtBackfill(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := Dial(ctx, &conf.PostgresConfiguration{Driver: DriverSQLite}, zap.NewNop())
+	require.NoError(t, err)
+	defer r.Close()
+
+	migrator, err := NewMigrator(r.DB)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx, 3)
+	require.NoError(t, err)
+
+	// a session started before sessions expired
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"}
+	require.NoError(t, r.Users().Create(ctx, &user))
+	createdAt := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, r.DB.Exec("INSERT INTO sessions (id, user_id, created_at) VALUES (?, ?, ?)", "5f0c9a52-1c55-4c1e-8a39-3f4b0b8f6a21", user.ID, createdAt).Error)
+
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+
+	active, err := r.Sessions().ListActive(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.WithinDuration(t, createdAt.AddDate(0, 0, 365), active[0].ExpiresAt, time.Second)
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS password_histories;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS email_changes;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,2 @@
+-- emails are unique regardless of case, run the normalize-emails command first if this fails on collisions
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
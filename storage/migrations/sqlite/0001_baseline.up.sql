@@ -0,0 +1,84 @@
+-- the postgres baseline for SQLite, uuids are stored as text and timestamps as datetime.
+-- SQLite lacks ADD COLUMN IF NOT EXISTS, the migrator skips the columns that exist.
+CREATE TABLE IF NOT EXISTS users (
+    id text PRIMARY KEY,
+    first_name varchar(255) NOT NULL,
+    last_name varchar(255) NOT NULL,
+    email varchar(255) NOT NULL UNIQUE,
+    role varchar(255),
+    confirmed boolean DEFAULT false,
+    password text,
+    created_at datetime,
+    updated_at datetime
+);
+-- the profile and deletion columns came after the first AutoMigrate, older tables lack them
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name varchar(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url varchar(2048);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone varchar(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language varchar(35);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS delete_after datetime;
+CREATE INDEX IF NOT EXISTS idx_users_delete_after ON users (delete_after);
+
+CREATE TABLE IF NOT EXISTS email_changes (
+    id text PRIMARY KEY,
+    user_id text NOT NULL,
+    old_email varchar(255) NOT NULL,
+    new_email varchar(255) NOT NULL,
+    expires_at datetime NOT NULL,
+    confirmed_at datetime,
+    undo_expires_at datetime,
+    reverted_at datetime,
+    created_at datetime,
+    updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes (user_id);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id text PRIMARY KEY,
+    actor_id text,
+    subject_id text,
+    type varchar(64) NOT NULL,
+    client_ip varchar(64),
+    user_agent varchar(512),
+    outcome varchar(16) NOT NULL,
+    reason varchar(255),
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject_id ON audit_events (subject_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id text PRIMARY KEY,
+    user_id text,
+    email varchar(255),
+    success boolean NOT NULL,
+    client_ip varchar(64),
+    user_agent varchar(512),
+    fingerprint varchar(64),
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_fingerprint ON login_attempts (fingerprint);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts (created_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id text PRIMARY KEY,
+    user_id text NOT NULL,
+    fingerprint varchar(64),
+    device varchar(512),
+    client_ip varchar(64),
+    last_used_at datetime,
+    revoked_at datetime,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS password_histories (
+    id text PRIMARY KEY,
+    user_id text NOT NULL,
+    password text NOT NULL,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_password_histories_user_id ON password_histories (user_id);
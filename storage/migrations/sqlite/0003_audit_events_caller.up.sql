@@ -0,0 +1,2 @@
+-- the certificate identity of the calling service under mutual TLS
+ALTER TABLE audit_events ADD COLUMN caller varchar(255);
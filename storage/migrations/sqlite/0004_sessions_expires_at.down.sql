@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+ALTER TABLE sessions DROP COLUMN expires_at;
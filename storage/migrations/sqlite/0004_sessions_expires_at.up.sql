@@ -0,0 +1,4 @@
+-- sessions expire with their refresh token, older sessions get the default lifetime of a year
+ALTER TABLE sessions ADD COLUMN expires_at datetime;
+UPDATE sessions SET expires_at = datetime(created_at, '+365 days') WHERE expires_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
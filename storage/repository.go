@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicateEmail is returned when an email is already taken by another user
+var ErrDuplicateEmail = errors.New("email already exists")
+
+// Repository gives access to everything the service stores
+type Repository interface {
+	Users() UserRepository
+	EmailChanges() EmailChangeRepository
+	Sessions() SessionRepository
+	LoginAttempts() LoginAttemptRepository
+	AuditEvents() AuditEventRepository
+	// Ping returns an error if the storage is unreachable
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// UserRepository stores the users and their previous password hashes
+type UserRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (models.User, error)
+	// FindByEmail matches the email case insensitive, to also find addresses stored before emails were normalized
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	// Update stores the given columns of the user
+	Update(ctx context.Context, user *models.User, columns ...string) error
+	SetConfirmed(ctx context.Context, user *models.User) error
+	// UpdatePassword replaces the password hash and remembers the previous one, only
+	// historySize previous hashes are kept
+	UpdatePassword(ctx context.Context, user *models.User, hash string, historySize int) error
+	// PasswordHistory returns up to limit previous password hashes, newest first
+	PasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistory, error)
+	// ListConfirmed returns the confirmed users that are not scheduled for deletion
+	ListConfirmed(ctx context.Context) ([]models.User, error)
+	// ListDeletionDue returns the users whose deletion is due at the given time
+	ListDeletionDue(ctx context.Context, at time.Time) ([]models.User, error)
+	// Purge removes everything stored about the user, except the audit events. Anonymized
+	// users are kept without personal data instead of being deleted.
+	Purge(ctx context.Context, user *models.User, anonymize bool) error
+}
+
+// EmailChangeRepository stores the requested changes of email addresses
+type EmailChangeRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (models.EmailChange, error)
+	Create(ctx context.Context, change *models.EmailChange) error
+	// Apply stores the change and sets the email of the user in one transaction
+	Apply(ctx context.Context, change *models.EmailChange, user *models.User, email string) error
+	// ListByUser returns the email changes of the user, oldest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.EmailChange, error)
+}
+
+// SessionRepository stores the sessions started on login
+type SessionRepository interface {
+	// FindByID returns the session with the given id if it belongs to the user
+	FindByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Session, error)
+	Create(ctx context.Context, session *models.Session) error
+	// Touch records the use of the session from the given ip address
+	Touch(ctx context.Context, session *models.Session, clientIP string) error
+	Revoke(ctx context.Context, session *models.Session) error
+	// RevokeAll revokes every active session of the user
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+	// ListActive returns the sessions of the user that are neither revoked nor expired,
+	// most recently used first
+	ListActive(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	// ListByUser returns all sessions of the user, oldest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	// DeleteExpired removes the sessions expired before the given time and returns their number
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginAttemptRepository stores the successful and failed logins
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *models.LoginAttempt) error
+	// CountSuccessful counts the successful logins of the user, only those from the
+	// device with the given fingerprint unless it is empty
+	CountSuccessful(ctx context.Context, userID uuid.UUID, fingerprint string) (int64, error)
+	// Recent returns up to limit logins of the user, newest first
+	Recent(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginAttempt, error)
+	// ListByUser returns all logins of the user, oldest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.LoginAttempt, error)
+}
+
+// AuditFilter selects audit events, empty fields match every event
+type AuditFilter struct {
+	ActorID   *uuid.UUID
+	SubjectID *uuid.UUID
+	Type      string
+	Outcome   string
+	Since     *time.Time
+	Until     *time.Time
+	Limit     int
+	Offset    int
+}
+
+// AuditEventRepository stores the append-only audit events
+type AuditEventRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// List returns the events matching the filter, newest first
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, error)
+	// ListByUser returns the events the user was actor or subject of, oldest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.AuditEvent, error)
+	// DeleteBefore removes the events created before the given time and returns their number
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
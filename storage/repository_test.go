@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// repositories returns every implementation, the GORM one on a migrated in-memory SQLite database
+func repositories(t *testing.T) map[string]Repository {
+	ctx := context.Background()
+
+	gormRepository, err := Dial(ctx, &conf.PostgresConfiguration{Driver: DriverSQLite}, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { gormRepository.Close() })
+
+	migrator, err := NewMigrator(gormRepository.DB)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+
+	return map[string]Repository{
+		"memory": NewMemoryRepository(),
+		"gorm":   gormRepository,
+	}
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	ctx := context.Background()
+
+	for name, r := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ada := models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"}
+			grace := models.User{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com"}
+			require.NoError(t, r.Users().Create(ctx, &ada))
+			require.NoError(t, r.Users().Create(ctx, &grace))
+
+			_, err := r.Users().FindByID(ctx, uuid.New())
+			assert.Equal(t, ErrNotFound, err)
+			_, err = r.Users().FindByEmail(ctx, "alan@example.com")
+			assert.Equal(t, ErrNotFound, err)
+			_, err = r.Sessions().FindByID(ctx, uuid.New(), ada.ID)
+			assert.Equal(t, ErrNotFound, err)
+
+			assert.Equal(t, ErrDuplicateEmail, r.Users().Create(ctx, &models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"}))
+			assert.Equal(t, ErrDuplicateEmail, r.Users().Create(ctx, &models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ADA@example.com"}))
+
+			grace.Email = "Ada@Example.com"
+			assert.Equal(t, ErrDuplicateEmail, r.Users().Update(ctx, &grace, "email"))
+
+			change := models.EmailChange{UserID: grace.ID, OldEmail: "grace@example.com", NewEmail: "ada@example.com"}
+			require.NoError(t, r.EmailChanges().Create(ctx, &change))
+			assert.Equal(t, ErrDuplicateEmail, r.EmailChanges().Apply(ctx, &change, &grace, change.NewEmail))
+
+			found, err := r.Users().FindByID(ctx, grace.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, "grace@example.com", found.Email)
+		})
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+
+	for name, r := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			user := models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "hash-1"}
+			require.NoError(t, r.Users().Create(ctx, &user))
+
+			for _, hash := range []string{"hash-2", "hash-3", "hash-4"} {
+				require.NoError(t, r.Users().UpdatePassword(ctx, &user, hash, 2))
+			}
+			history, err := r.Users().PasswordHistory(ctx, user.ID, 10)
+			assert.Nil(t, err)
+			require.Len(t, history, 2)
+			assert.Equal(t, "hash-3", history[0].Password)
+			assert.Equal(t, "hash-2", history[1].Password)
+
+			// without a history nothing is remembered and the old hashes are dropped
+			require.NoError(t, r.Users().UpdatePassword(ctx, &user, "hash-5", 0))
+			history, _ = r.Users().PasswordHistory(ctx, user.ID, 10)
+			assert.Empty(t, history)
+		})
+	}
+}
+
+func TestDuplicateEmail(t *testing.T) {
+	assert.Nil(t, duplicateEmail(nil))
+	assert.Equal(t, ErrDuplicateEmail, duplicateEmail(&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email_lower"}))
+	assert.Equal(t, ErrDuplicateEmail, duplicateEmail(fmt.Errorf("creating user: %w", &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"})))
+
+	other := &pgconn.PgError{Code: "23505", ConstraintName: "sessions_pkey"}
+	assert.Equal(t, error(other), duplicateEmail(other))
+}
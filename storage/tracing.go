@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"github.com/hiltpold/lakelandcup-auth-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// tracingPlugin creates a span for every query as a child of the span in the
+// context of the statement, see gorm.DB.WithContext. The statement is recorded
+// with its placeholders, never with its values.
+type tracingPlugin struct{}
+
+func (tracingPlugin) Name() string {
+	return "tracing"
+}
+
+// dbSystem returns the db.system attribute of the database driver, which names postgres
+// postgresql while SQLite keeps its name
+func dbSystem(db *gorm.DB) string {
+	if db.Dialector.Name() == DriverPostgres {
+		return "postgresql"
+	}
+	return db.Dialector.Name()
+}
+
+func (tracingPlugin) Initialize(db *gorm.DB) error {
+	system := dbSystem(db)
+	before := func(operation string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			_, span := tracing.Tracer.Start(db.Statement.Context, "gorm."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", system), attribute.String("db.operation", operation)),
+			)
+			db.InstanceSet(spanKey, span)
+		}
+	}
+	after := func(db *gorm.DB) {
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		span.SetAttributes(
+			attribute.String("db.sql.table", db.Statement.Table),
+			attribute.String("db.statement", db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+		)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			tracing.End(span, db.Error)
+			return
+		}
+		span.End()
+	}
+
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", after),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", after),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", after),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", after),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
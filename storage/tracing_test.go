@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiltpold/lakelandcup-auth-service/conf"
+	"github.com/hiltpold/lakelandcup-auth-service/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestDBSystem(t *testing.T) {
+	assert.Equal(t, "postgresql", dbSystem(&gorm.DB{Config: &gorm.Config{Dialector: postgres.Open("")}}))
+}
+
+func TestTracingPlugin(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	previous := tracing.Tracer
+	tracing.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	defer func() { tracing.Tracer = previous }()
+
+	r, err := Dial(ctx, &conf.PostgresConfiguration{Driver: DriverSQLite}, zap.NewNop())
+	require.NoError(t, err)
+	defer r.Close()
+	migrator, err := NewMigrator(r.DB)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+
+	_, err = r.Users().FindByEmail(ctx, "ada@example.com")
+	assert.Equal(t, ErrNotFound, err)
+
+	var query sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "gorm.query" {
+			query = span
+		}
+	}
+	require.NotNil(t, query)
+	assert.Contains(t, query.Attributes(), attribute.String("db.system", "sqlite"))
+	assert.Contains(t, query.Attributes(), attribute.String("db.sql.table", "users"))
+	assert.Contains(t, query.Attributes(), attribute.String("db.operation", "query"))
+}
@@ -2,10 +2,10 @@ package test
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/hiltpold/lakelandcup-auth-service/conf"
@@ -14,7 +14,6 @@ import (
 	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
 	"github.com/hiltpold/lakelandcup-auth-service/storage"
 	"github.com/hiltpold/lakelandcup-auth-service/utils"
-	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/test/bufconn"
@@ -29,14 +28,50 @@ var db *gorm.DB
 var client pb.AuthServiceClient
 var ctx context.Context
 var conn *grpc.ClientConn
+var mailer = &recordingMailer{tokens: map[string]string{}}
+
+// recordingMailer keeps the token of the last mail to every recipient instead of sending it
+type recordingMailer struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (m *recordingMailer) Send(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[email] = data["token"]
+	return nil
+}
+
+func (m *recordingMailer) token(email string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[email]
+}
 
 func bufDialer(context.Context, string) (net.Conn, error) {
 	return lis.Dial()
 }
 
 func setupServer(c *conf.Configuration) {
-	h := storage.Dial(&c.DB)
+	if err := storage.Init(context.Background(), &c.DB, utils.Logger()); err != nil {
+		log.Fatalf("Failed to initialize the database: %v", err)
+	}
+
+	h, err := storage.Dial(context.Background(), &c.DB, utils.Logger())
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
 	db = h.DB
+
+	migrator, err := storage.NewMigrator(h.DB)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		log.Fatalf("Failed to migrate: %v", err)
+	}
+
 	jwt := utils.JwtWrapper{
 		TokenKey:        c.API.TokenSecretKey,
 		Issuer:          "lakelandcup-auth-service-test",
@@ -45,8 +80,10 @@ func setupServer(c *conf.Configuration) {
 
 	lis = bufconn.Listen(bufSize)
 	s := service.Server{
-		R:   h,
-		Jwt: jwt,
+		R:      h,
+		Jwt:    jwt,
+		Conf:   *c,
+		Mailer: mailer,
 	}
 	grpcServer := grpc.NewServer()
 	pb.RegisterAuthServiceServer(grpcServer, &s)
@@ -61,7 +98,7 @@ func setupClient() (pb.AuthServiceClient, context.Context, *grpc.ClientConn) {
 	ctx := context.Background()
 	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithInsecure())
 	if err != nil {
-		logrus.Fatalf("Failed to dial bufnet: %v", err)
+		log.Fatalf("Failed to dial bufnet: %v", err)
 	}
 	c := pb.NewAuthServiceClient(conn)
 	return c, ctx, conn
@@ -70,7 +107,7 @@ func setupClient() (pb.AuthServiceClient, context.Context, *grpc.ClientConn) {
 func setup() (pb.AuthServiceClient, context.Context, *grpc.ClientConn) {
 	c, err := conf.LoadConfig(testConfig)
 	if err != nil {
-		logrus.Fatal(fmt.Sprintf("Failed to load config %s.", testConfig), err)
+		log.Fatalf("Failed to load config %s: %v", testConfig, err)
 	}
 	setupServer(c)
 	return setupClient()
@@ -138,6 +175,21 @@ func TestRegisterAndLogin(t *testing.T) {
 	}
 	loginReq := pb.LoginRequest{Password: "password", Email: "max.muster@gmail.com"}
 
+	// unconfirmed users can not log in
+	unconfirmedResp, err1 := client.Login(ctx, &loginReq)
+	log.Printf("Response: %+v", unconfirmedResp)
+
+	if err1 != nil {
+		t.Fatalf("Login failed: %v", err1)
+	}
+
+	activateResp, errActivate := client.Activate(ctx, &pb.ActivateRequest{Token: mailer.token(registerReq.Email)})
+	log.Printf("Response: %+v", activateResp)
+
+	if errActivate != nil {
+		t.Fatalf("Activation failed: %v", errActivate)
+	}
+
 	loginResp, err2 := client.Login(ctx, &loginReq)
 	log.Printf("Response: %+v", loginResp)
 
@@ -147,8 +199,51 @@ func TestRegisterAndLogin(t *testing.T) {
 	// Test for response here.
 	assert.Equal(t, registerResp.Status, int64(201))
 	assert.Equal(t, registerResp.Error, "")
+	assert.Equal(t, unconfirmedResp.Status, int64(403))
+	assert.Equal(t, unconfirmedResp.Error, "User not yet Confirmed")
+	assert.Equal(t, activateResp.Status, int64(200))
 	assert.Equal(t, loginResp.Status, int64(200))
 	assert.Equal(t, loginResp.Error, "")
 	// Clean Up
 	db.Where("Email = ?", registerReq.Email).Delete(&models.User{})
 }
+
+func TestRequestEmailChangeInvalidUser(t *testing.T) {
+	changeReq := pb.RequestEmailChangeRequest{UserID: "no-uuid", Email: "max.muster@gmail.com", Password: "password"}
+
+	changeResp, err := client.RequestEmailChange(ctx, &changeReq)
+	if err != nil {
+		t.Fatalf("Request email change failed: %v", err)
+	}
+	log.Printf("Response: %+v", changeResp)
+
+	assert.Equal(t, changeResp.Status, int64(400))
+	assert.Equal(t, changeResp.Error, "Invalid user id")
+}
+
+func TestDeleteAccountUnknownUser(t *testing.T) {
+	deleteReq := pb.DeleteAccountRequest{UserID: "00000000-0000-0000-0000-000000000000", Password: "password"}
+
+	deleteResp, err := client.DeleteAccount(ctx, &deleteReq)
+	if err != nil {
+		t.Fatalf("Delete account failed: %v", err)
+	}
+	log.Printf("Response: %+v", deleteResp)
+
+	assert.Equal(t, deleteResp.Status, int64(404))
+	assert.Equal(t, deleteResp.Error, "No such user")
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	forgotReq := pb.ForgotPasswordRequest{Email: "nobody@lakelandcup.ch"}
+
+	forgotResp, err := client.ForgotPassword(ctx, &forgotReq)
+	if err != nil {
+		t.Fatalf("Forgot password failed: %v", err)
+	}
+	log.Printf("Response: %+v", forgotResp)
+
+	// without ENUMERATION_SAFE the response reveals that the email is unknown
+	assert.Equal(t, forgotResp.Status, int64(404))
+	assert.Equal(t, forgotResp.Error, "Email was never registered")
+}
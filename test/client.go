@@ -5,7 +5,9 @@ import (
 
 	"github.com/hiltpold/lakelandcup-auth-service/conf"
 	"github.com/hiltpold/lakelandcup-auth-service/service/pb"
+	"github.com/hiltpold/lakelandcup-auth-service/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type ServiceClient struct {
@@ -13,8 +15,19 @@ type ServiceClient struct {
 }
 
 func InitServiceClient(c *conf.Configuration) pb.AuthServiceClient {
-	// using WithInsecure() because no SSL running
-	cc, err := grpc.Dial(c.API.Host, grpc.WithInsecure())
+	// using WithInsecure() unless TLS is configured
+	opt := grpc.WithInsecure()
+
+	if c.TLS.CertFile != "" {
+		// the test setup signs the server and client certificates with the same CA
+		config, err := utils.ClientConfig(c.TLS.CertFile, c.TLS.KeyFile, c.TLS.ClientCAFile)
+		if err != nil {
+			fmt.Println("Could not load TLS certificate:", err)
+		}
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(config))
+	}
+
+	cc, err := grpc.Dial(c.API.Host, opt)
 
 	if err != nil {
 		fmt.Println("Could not connect:", err)
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/hiltpold/lakelandcup-auth-service"
+
+// Tracer creates the spans of the service. Until Setup installs an exporter
+// the global no-op provider is used and spans are dropped.
+var Tracer trace.Tracer = otel.Tracer(instrumentation)
+
+// Sampler samples the given ratio of traces, every trace if it is empty and none at all if
+// it is 0. Traces continued from a caller follow its decision unless sampling is off.
+func Sampler(ratio string) (sdktrace.Sampler, error) {
+	if ratio == "" {
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	}
+
+	value, err := strconv.ParseFloat(ratio, 64)
+	if err != nil || value < 0 || value > 1 {
+		return nil, fmt.Errorf("sample ratio must be a number between 0 and 1, got %s", ratio)
+	}
+	if value == 0 {
+		return sdktrace.NeverSample(), nil
+	}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(value)), nil
+}
+
+// Setup exports spans over OTLP/gRPC to the endpoint and returns a function flushing
+// the remaining spans on shutdown. Without an endpoint tracing stays disabled.
+func Setup(ctx context.Context, service string, endpoint string, insecure bool, ratio string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	sampler, err := Sampler(ratio)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(service),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
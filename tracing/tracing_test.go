@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupWithoutEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.Background(), "auth", "", false, "1")
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestSampler(t *testing.T) {
+	sampler, err := Sampler("")
+	assert.Nil(t, err)
+	assert.Contains(t, sampler.Description(), "AlwaysOnSampler")
+
+	sampler, err = Sampler("0")
+	assert.Nil(t, err)
+	assert.Equal(t, "AlwaysOffSampler", sampler.Description())
+
+	sampler, err = Sampler("0.25")
+	assert.Nil(t, err)
+	assert.Contains(t, sampler.Description(), "TraceIDRatioBased{0.25}")
+
+	for _, ratio := range []string{"-0.5", "1.5", "all"} {
+		_, err = Sampler(ratio)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := tracer.Start(context.Background(), "failed")
+	End(failed, errors.New("query failed"))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "query failed", spans[1].Status().Description)
+}
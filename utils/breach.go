@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const breachHashSize = sha1.Size
+
+// BreachedPasswords is a set of SHA-1 hashes of breached passwords, stored as one
+// sorted byte slice of 20 byte hashes and searched with a binary search.
+type BreachedPasswords struct {
+	hashes []byte
+}
+
+// LoadBreachedPasswords reads an index built by BuildBreachIndex
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	hashes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hashes)%breachHashSize != 0 {
+		return nil, fmt.Errorf("invalid breach index %s: size is not a multiple of %d", path, breachHashSize)
+	}
+
+	return &BreachedPasswords{hashes: hashes}, nil
+}
+
+// Len returns the number of hashes in the set
+func (b *BreachedPasswords) Len() int {
+	return len(b.hashes) / breachHashSize
+}
+
+// Contains reports whether the password appears in the set
+func (b *BreachedPasswords) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	i := sort.Search(b.Len(), func(i int) bool {
+		return bytes.Compare(b.hashes[i*breachHashSize:(i+1)*breachHashSize], sum[:]) >= 0
+	})
+
+	return i < b.Len() && bytes.Equal(b.hashes[i*breachHashSize:(i+1)*breachHashSize], sum[:])
+}
+
+type breachHashes []byte
+
+func (h breachHashes) Len() int { return len(h) / breachHashSize }
+
+func (h breachHashes) Less(i, j int) bool {
+	return bytes.Compare(h[i*breachHashSize:(i+1)*breachHashSize], h[j*breachHashSize:(j+1)*breachHashSize]) < 0
+}
+
+func (h breachHashes) Swap(i, j int) {
+	var tmp [breachHashSize]byte
+	copy(tmp[:], h[i*breachHashSize:])
+	copy(h[i*breachHashSize:(i+1)*breachHashSize], h[j*breachHashSize:(j+1)*breachHashSize])
+	copy(h[j*breachHashSize:(j+1)*breachHashSize], tmp[:])
+}
+
+// BuildBreachIndex reads the Pwned Passwords SHA-1 format, one HASH:COUNT per line, and writes
+// the sorted and deduplicated hashes seen at least minCount times. Lines of the range API
+// only contain the hash suffix, prefix is prepended to them and may be empty otherwise.
+func BuildBreachIndex(r io.Reader, w io.Writer, prefix string, minCount int) (int, error) {
+	var hashes breachHashes
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		hash, count, found := strings.Cut(text, ":")
+		if found && minCount > 1 {
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid count %q", line, count)
+			}
+			if n < minCount {
+				continue
+			}
+		}
+
+		sum, err := hex.DecodeString(prefix + hash)
+		if err != nil || len(sum) != breachHashSize {
+			return 0, fmt.Errorf("line %d: invalid SHA-1 hash %q", line, prefix+hash)
+		}
+		hashes = append(hashes, sum...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	sort.Sort(hashes)
+
+	n := 0
+	for i := 0; i < hashes.Len(); i++ {
+		current := hashes[i*breachHashSize : (i+1)*breachHashSize]
+		if n > 0 && bytes.Equal(hashes[(n-1)*breachHashSize:n*breachHashSize], current) {
+			continue
+		}
+		copy(hashes[n*breachHashSize:], current)
+		n++
+	}
+
+	if _, err := w.Write(hashes[:n*breachHashSize]); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
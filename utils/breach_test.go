@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreachIndex(t *testing.T) {
+	// SHA-1 of "password", "123456" and "qwerty", the first one twice
+	raw := strings.Join([]string{
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824",
+		"7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195",
+		"B1B3773A05C0ED0176787A4F1574FF0075F7521E:2",
+		"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:9545824",
+	}, "\n")
+
+	var index bytes.Buffer
+	n, err := BuildBreachIndex(strings.NewReader(raw), &index, "", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	path := filepath.Join(t.TempDir(), "breached.idx")
+	assert.Nil(t, os.WriteFile(path, index.Bytes(), 0o600))
+
+	breached, err := LoadBreachedPasswords(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, breached.Len())
+	assert.True(t, breached.Contains("password"))
+	assert.True(t, breached.Contains("123456"))
+	assert.False(t, breached.Contains("qwerty"))
+	assert.False(t, breached.Contains("correct horse battery staple"))
+
+	var disabled *BreachedPasswords
+	assert.False(t, disabled.Contains("password"))
+
+	_, err = BuildBreachIndex(strings.NewReader("not a hash:1"), &index, "", 1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrInvalidEmail = errors.New("Invalid email address")
+
+// NormalizeEmail trims and lowercases the address and converts an internationalized
+// domain to its ASCII form, so that every spelling of an address maps to one account.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+
+	domain, err = idna.Lookup.ToASCII(domain)
+	if err != nil || !strings.Contains(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(local) + "@" + strings.ToLower(domain), nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	for input, expected := range map[string]string{
+		"max@gmail.com":        "max@gmail.com",
+		"  Max@Gmail.COM ":     "max@gmail.com",
+		"fan@Bücher.example":   "fan@xn--bcher-kva.example",
+		"first.last@sub.EX.ch": "first.last@sub.ex.ch",
+	} {
+		normalized, err := NormalizeEmail(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, normalized)
+	}
+
+	for _, input := range []string{"", "max", "max@", "@gmail.com", "Max <max@gmail.com>", "max@localhost", "max@exa mple.com"} {
+		_, err := NormalizeEmail(input)
+		assert.Equal(t, ErrInvalidEmail, err, input)
+	}
+}
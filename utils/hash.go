@@ -1,15 +1,175 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-func HashPassword(password string) (string, error) {
-	bytes, error := bcrypt.GenerateFromPassword([]byte(password), 5)
+	"github.com/hiltpold/lakelandcup-auth-service/metrics"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
 
-	return string(bytes), error
+// Hasher hashes passwords into an encoded format whose prefix identifies the algorithm.
+type Hasher interface {
+	// Hash returns the encoded hash of the password
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the encoded hash
+	Verify(password string, encoded string) (bool, error)
+	// Matches reports whether the encoded hash was produced by this algorithm
+	Matches(encoded string) bool
+	// Outdated reports whether the encoded hash was produced with other parameters
+	Outdated(encoded string) bool
 }
 
-func CheckPasswordHash(password string, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+// BcryptHasher produces $2a$ encoded bcrypt hashes
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h BcryptHasher) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher produces hashes in the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Time        uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func decodeArgon2id(encoded string) (*argon2idHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("unsupported argon2id version")
+	}
+
+	h := argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.parallelism); err != nil {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("invalid argon2id salt")
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, errors.New("invalid argon2id key")
+	}
+
+	return &h, nil
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password string, encoded string) (bool, error) {
+	decoded, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.time, decoded.memory, decoded.parallelism, uint32(len(decoded.key)))
+
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+}
+
+func (h Argon2idHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Outdated(encoded string) bool {
+	decoded, err := decodeArgon2id(encoded)
+	return err != nil || decoded.memory != h.Memory || decoded.time != h.Time || decoded.parallelism != h.Parallelism ||
+		uint32(len(decoded.salt)) != h.SaltLength || uint32(len(decoded.key)) != h.KeyLength
+}
+
+// PasswordHasher hashes new passwords with the current hasher and verifies
+// hashes of every supported algorithm, so existing hashes keep working after
+// the algorithm or its parameters change.
+type PasswordHasher struct {
+	Current Hasher
+	Known   []Hasher
+}
+
+// NewPasswordHasher returns a PasswordHasher using current for new hashes
+// that is able to verify both argon2id and bcrypt hashes.
+func NewPasswordHasher(current Hasher) *PasswordHasher {
+	return &PasswordHasher{
+		Current: current,
+		Known:   []Hasher{current, Argon2idHasher{}, BcryptHasher{}},
+	}
+}
+
+func (p *PasswordHasher) Hash(password string) (string, error) {
+	defer observeHash("hash", time.Now())
+	return p.Current.Hash(password)
+}
+
+// Verify reports whether the password matches the encoded hash of any known algorithm
+func (p *PasswordHasher) Verify(password string, encoded string) bool {
+	defer observeHash("verify", time.Now())
+	for _, h := range p.Known {
+		if h.Matches(encoded) {
+			match, err := h.Verify(password, encoded)
+			return err == nil && match
+		}
+	}
+	return false
+}
+
+func observeHash(operation string, start time.Time) {
+	metrics.PasswordHashDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
 
-	return err == nil
+// NeedsRehash reports whether the encoded hash should be replaced by a hash of the current hasher
+func (p *PasswordHasher) NeedsRehash(encoded string) bool {
+	return !p.Current.Matches(encoded) || p.Current.Outdated(encoded)
 }
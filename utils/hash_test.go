@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHasherVerifiesAndRehashes(t *testing.T) {
+	bcryptHasher := NewPasswordHasher(BcryptHasher{Cost: 4})
+	argon2Hasher := NewPasswordHasher(Argon2idHasher{Memory: 1024, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	legacy, err := bcryptHasher.Hash("password")
+	assert.Nil(t, err)
+
+	// hashes of another algorithm still verify but need a rehash
+	assert.True(t, argon2Hasher.Verify("password", legacy))
+	assert.False(t, argon2Hasher.Verify("wrong", legacy))
+	assert.True(t, argon2Hasher.NeedsRehash(legacy))
+
+	current, err := argon2Hasher.Hash("password")
+	assert.Nil(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=1024,t=1,p=1\$`, current)
+	assert.True(t, argon2Hasher.Verify("password", current))
+	assert.False(t, argon2Hasher.Verify("wrong", current))
+	assert.False(t, argon2Hasher.NeedsRehash(current))
+
+	stronger := NewPasswordHasher(Argon2idHasher{Memory: 2048, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	assert.True(t, stronger.Verify("password", current))
+	assert.True(t, stronger.NeedsRehash(current))
+}
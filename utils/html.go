@@ -3,34 +3,44 @@ package utils
 import (
 	"bytes"
 	"fmt"
-	"text/template"
-
-	"github.com/sirupsen/logrus"
+	"html/template"
 )
 
 type BodyRequest struct {
 	To            string
 	Token         string
 	ActivationUrl string
+	Url           string
+	Device        string
+	IP            string
+	Time          string
 }
 
-func ParseHtml(fileName string, data map[string]string) string {
+// ParseHtml renders the mail template with the given data, which is escaped since
+// values like the device of a sign-in are chosen by the caller
+func ParseHtml(fileName string, data map[string]string) (string, error) {
 	html, errParse := template.ParseFiles("templates/" + fileName + ".html")
 
 	if errParse != nil {
-		defer fmt.Println("parser file html failed")
-		logrus.Fatal(errParse.Error())
+		return "", fmt.Errorf("parse html template %s failed: %w", fileName, errParse)
 	}
 
-	body := BodyRequest{To: data["to"], Token: data["token"], ActivationUrl: data["activationUrl"]}
+	body := BodyRequest{
+		To:            data["to"],
+		Token:         data["token"],
+		ActivationUrl: data["activationUrl"],
+		Url:           data["url"],
+		Device:        data["device"],
+		IP:            data["ip"],
+		Time:          data["time"],
+	}
 
 	buf := new(bytes.Buffer)
 	errExecute := html.Execute(buf, body)
 
 	if errExecute != nil {
-		defer fmt.Println("execute html file failed")
-		logrus.Fatal(errExecute.Error())
+		return "", fmt.Errorf("execute html template %s failed: %w", fileName, errExecute)
 	}
 
-	return buf.String()
+	return buf.String(), nil
 }
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHtmlEscapes(t *testing.T) {
+	// the templates live in the repository root
+	wd, _ := os.Getwd()
+	assert.Nil(t, os.Chdir(".."))
+	defer os.Chdir(wd)
+
+	body, err := ParseHtml("new-device", map[string]string{
+		"to":     "ada@example.com",
+		"device": `<a href="https://evil.example">Reset your password here</a>`,
+		"ip":     "<script>alert(1)</script>",
+	})
+	assert.Nil(t, err)
+	assert.NotContains(t, body, `<a href="https://evil.example">`)
+	assert.NotContains(t, body, "<script>")
+	assert.Contains(t, body, "&lt;a href=&#34;https://evil.example&#34;&gt;")
+	assert.Contains(t, body, "ada@example.com")
+}
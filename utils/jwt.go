@@ -21,15 +21,20 @@ type JwtWrapper struct {
 
 type jwtClaims struct {
 	jwt.StandardClaims
-	Id    uuid.UUID
-	Email string
-	Role  string
+	Id        uuid.UUID
+	Email     string
+	Role      string
+	SessionId uuid.UUID
+	// DeleteAfter binds an account deletion cancel token to the deletion it was mailed for
+	DeleteAfter int64 `json:",omitempty"`
 }
 
 type JwtData struct {
-	Id    uuid.UUID
-	Email string
-	Role  string
+	Id          uuid.UUID
+	Email       string
+	Role        string
+	SessionId   uuid.UUID
+	DeleteAfter time.Time
 }
 
 func (w *JwtWrapper) GenerateToken(data JwtData, tokenType string) (signedToken string, err error) {
@@ -38,9 +43,13 @@ func (w *JwtWrapper) GenerateToken(data JwtData, tokenType string) (signedToken
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(w.ExpirationHours)).Unix(),
 			Issuer:    w.Issuer,
 		},
-		Id:    data.Id,
-		Email: data.Email,
-		Role:  data.Role,
+		Id:        data.Id,
+		Email:     data.Email,
+		Role:      data.Role,
+		SessionId: data.SessionId,
+	}
+	if !data.DeleteAfter.IsZero() {
+		claims.DeleteAfter = data.DeleteAfter.UnixMilli()
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -50,6 +59,8 @@ func (w *JwtWrapper) GenerateToken(data JwtData, tokenType string) (signedToken
 	case "REFRESH_TOKEN":
 		signedToken, err = token.SignedString([]byte(w.RefreshTokenKey))
 	default:
+		// tokens signed with the generic key are bound to their purpose
+		claims.Audience = tokenType
 		signedToken, err = token.SignedString([]byte(w.TokenKey))
 	}
 	if err != nil {
@@ -89,6 +100,10 @@ func (w *JwtWrapper) ValidateToken(signedToken string, tokenType string) (claims
 		return nil, errors.New("JWT is expired")
 	}
 
+	if tokenType != "ACCESS_TOKEN" && tokenType != "REFRESH_TOKEN" && claims.Audience != tokenType {
+		return nil, errors.New("JWT was issued for another purpose")
+	}
+
 	return claims, nil
 
 }
@@ -1,23 +1,68 @@
 package utils
 
 import (
+	"context"
+	"net"
 	"os"
 
+	"github.com/hiltpold/lakelandcup-auth-service/metrics"
+	"github.com/hiltpold/lakelandcup-auth-service/tracing"
 	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SendGridMail(name string, email string, subject string, fileName string, token string, sgKey string) (*rest.Response, error) {
+// Mailer sends the mail rendered from a template, see BodyRequest for the supported data keys
+type Mailer interface {
+	Send(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string) error
+}
+
+// SendGridMailer sends mails over the SendGrid API
+type SendGridMailer struct {
+	Key string
+}
+
+func (m SendGridMailer) Send(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string) error {
+	_, err := SendGridTemplateMail(ctx, name, email, subject, fileName, data, m.Key)
+	return err
+}
+
+// SendGridTemplateMail sends a mail rendered from the given template and data, see BodyRequest for the supported keys
+func SendGridTemplateMail(ctx context.Context, name string, email string, subject string, fileName string, data map[string]string, sgKey string) (response *rest.Response, err error) {
+	_, span := tracing.Tracer.Start(ctx, "mail.send", trace.WithAttributes(attribute.String("mail.template", fileName)))
+	defer func() { tracing.End(span, err) }()
+
 	from := mail.NewEmail("Lakelandcup", os.Getenv("SENDGRID_EMAIL"))
 	to := mail.NewEmail(name, email)
 	subjectMail := subject
-	template := ParseHtml(fileName, map[string]string{
-		"to":            email,
-		"token":         token,
-		"activationUrl": os.Getenv("ACTIVATION_URL"),
-	})
+	data["to"] = email
+	data["activationUrl"] = os.Getenv("ACTIVATION_URL")
+	template, err := ParseHtml(fileName, data)
+	if err != nil {
+		metrics.Emails.WithLabelValues(fileName, "failed").Inc()
+		return nil, err
+	}
 	message := mail.NewSingleEmail(from, subjectMail, to, "", template)
 	client := sendgrid.NewSendClient(sgKey)
-	return client.Send(message)
+	response, err = client.Send(message)
+	failed := err != nil || response.StatusCode >= 400
+	if response != nil {
+		span.SetAttributes(attribute.Int("mail.status", response.StatusCode))
+	}
+	metrics.Emails.WithLabelValues(fileName, Ternary(failed, "failed", "sent")).Inc()
+	return response, err
+}
+
+const sendGridAddress = "api.sendgrid.com:443"
+
+// SendGridReachable returns an error if no connection to the SendGrid API can be opened
+func SendGridReachable(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", sendGridAddress)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
 }
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// InNetworks reports whether ip is one of the comma separated addresses or CIDR ranges
+func InNetworks(ip string, networks string) bool {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return false
+	}
+
+	for _, network := range strings.Split(networks, ",") {
+		network = strings.TrimSpace(network)
+		if network == "" {
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(network); err == nil {
+			if ipNet.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(network); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
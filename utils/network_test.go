@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInNetworks(t *testing.T) {
+	networks := "10.0.0.0/8, 192.168.1.10,fd00::/8"
+
+	assert.True(t, InNetworks("10.1.2.3", networks))
+	assert.True(t, InNetworks("192.168.1.10", networks))
+	assert.True(t, InNetworks("fd00::1", networks))
+	assert.False(t, InNetworks("192.168.1.11", networks))
+	assert.False(t, InNetworks("8.8.8.8", networks))
+	assert.False(t, InNetworks("not-an-ip", networks))
+	assert.False(t, InNetworks("10.1.2.3", ""))
+}
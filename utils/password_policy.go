@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FieldViolation describes why the value of a request field was rejected
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// PasswordPolicy defines the requirements new passwords have to meet.
+// Lengths are counted in characters of the NFKC normalized password,
+// MaxBytes limits its UTF-8 encoding, e.g. to the 72 bytes bcrypt considers.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	MaxBytes         int
+	RequireLower     bool
+	RequireUpper     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowPersonal bool
+}
+
+// NormalizePassword returns the NFKC normalization of the password, so that
+// equivalent unicode input hashes the same regardless of the client's encoding
+func NormalizePassword(password string) string {
+	return norm.NFKC.String(password)
+}
+
+// Check returns the violations of the normalized password against the policy. Personal
+// data such as the email or the names of the user must not be part of the password.
+func (p PasswordPolicy) Check(field string, password string, personal ...string) []FieldViolation {
+	var violations []FieldViolation
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, FieldViolation{Field: field, Description: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violate("Password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violate("Password must not be longer than %d characters", p.MaxLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violate("Password must not be longer than %d bytes", p.MaxBytes)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		violate("Password must contain a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		violate("Password must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violate("Password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violate("Password must contain a symbol")
+	}
+
+	if p.DisallowPersonal {
+		lowered := strings.ToLower(password)
+		for _, data := range personal {
+			for _, part := range personalParts(data) {
+				if strings.Contains(lowered, part) {
+					violate("Password must not contain your name or email address")
+					return violations
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+// personalParts splits personal data such as an email address into the lowercase
+// parts worth checking, parts shorter than three characters are ignored
+func personalParts(data string) []string {
+	var parts []string
+	for _, part := range strings.FieldsFunc(strings.ToLower(data), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(part) >= 3 {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
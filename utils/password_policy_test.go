@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 16, RequireDigit: true, DisallowPersonal: true}
+
+	assert.Empty(t, policy.Check("password", "correct horse 1", "jane.doe@example.com", "Jane", "Doe"))
+	assert.Len(t, policy.Check("password", "short1"), 1)
+	assert.Len(t, policy.Check("password", "no digits at all"), 1)
+	assert.Len(t, policy.Check("password", "this one is far too long 1"), 1)
+
+	violations := policy.Check("password", "janedoe2023", "jane.doe@example.com", "Jane", "Doe")
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "password", violations[0].Field)
+
+	// the fullwidth digit normalizes to an ASCII digit
+	assert.Equal(t, "secret12", NormalizePassword("secret1２"))
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// certReloadInterval is how often the certificate files are checked for changes
+const certReloadInterval = 10 * time.Second
+
+// CertReloader serves a certificate and an optional client CA bundle from disk and
+// reloads them once the files change, so rotated certificates are used without restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+
+	mu       sync.Mutex
+	checked  time.Time
+	modTimes []time.Time
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+// NewCertReloader loads the key pair and, if caFile is set, the CA bundle client certificates are verified against
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFile: caFile, interval: certReloadInterval}
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+
+	return r, nil
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *CertReloader) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (r *CertReloader) load(modTimes []time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading key pair: %w", err)
+	}
+
+	var clientCA *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("loading client CA: %w", err)
+		}
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(pem) {
+			return errors.New("loading client CA: no certificates found in " + r.caFile)
+		}
+	}
+
+	r.cert = &cert
+	r.clientCA = clientCA
+	r.modTimes = modTimes
+	return nil
+}
+
+func changed(a, b []time.Time) bool {
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the certificate and client CA bundle, reloading them if the files changed.
+// Files that can not be loaded, e.g. while they are being replaced, keep the previous ones in use.
+func (r *CertReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < r.interval {
+		return r.cert, r.clientCA
+	}
+	r.checked = time.Now()
+
+	modTimes, err := r.stat()
+	if err == nil && changed(r.modTimes, modTimes) {
+		err = r.load(modTimes)
+		if err == nil {
+			Info("Reloaded TLS certificate", zap.String("file", r.certFile))
+		}
+	}
+	if err != nil {
+		Warn("Reloading TLS certificate failed, keeping the current one", zap.Error(err))
+	}
+
+	return r.cert, r.clientCA
+}
+
+// ServerConfig returns a TLS configuration that uses the current certificate for every
+// handshake. With a client CA bundle, client certificates are verified against it and
+// are mandatory unless optional is set.
+func (r *CertReloader) ServerConfig(optional bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCA := r.current()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				// the returned config replaces the one gRPC adds its protocol to
+				NextProtos: []string{"h2"},
+			}
+			if clientCA != nil {
+				config.ClientCAs = clientCA
+				config.ClientAuth = Ternary(optional, tls.VerifyClientCertIfGiven, tls.RequireAndVerifyClientCert)
+			}
+			return config, nil
+		},
+	}
+}
+
+// ClientConfig returns a TLS configuration for dialing the service. The server certificate
+// is verified against caFile, or the system roots if empty, and the key pair is presented
+// as client certificate if given.
+func ClientConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + caFile)
+		}
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a certificate signed by parent, or a self-signed one if parent is nil
+func writeCert(t *testing.T, dir string, name string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	return cert, key
+}
+
+// handshake connects to a listener using config and returns the certificate of the server
+func handshake(t *testing.T, server *tls.Config, client *tls.Config) (*x509.Certificate, error) {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	assert.Nil(t, err)
+	defer lis.Close()
+
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the server verifies the client certificate after the client finished its handshake
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil && err != io.EOF {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return nil, err
+		}
+	}
+
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", 1, nil, nil)
+	writeCert(t, dir, "server", 2, ca, caKey)
+	writeCert(t, dir, "client", 3, ca, caKey)
+
+	reloader, err := NewCertReloader(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt"))
+	assert.Nil(t, err)
+	reloader.interval = 0
+
+	client, err := ClientConfig(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"), filepath.Join(dir, "ca.crt"))
+	assert.Nil(t, err)
+	client.ServerName = "localhost"
+
+	cert, err := handshake(t, reloader.ServerConfig(false), client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), cert.SerialNumber.Int64())
+
+	// without a client certificate the handshake fails unless it is optional
+	anonymous, err := ClientConfig("", "", filepath.Join(dir, "ca.crt"))
+	assert.Nil(t, err)
+	anonymous.ServerName = "localhost"
+	_, err = handshake(t, reloader.ServerConfig(false), anonymous)
+	assert.NotNil(t, err)
+	_, err = handshake(t, reloader.ServerConfig(true), anonymous)
+	assert.Nil(t, err)
+
+	// a rotated certificate is picked up without creating a new reloader
+	writeCert(t, dir, "server", 4, ca, caKey)
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "server.crt"), later, later))
+
+	cert, err = handshake(t, reloader.ServerConfig(false), client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), cert.SerialNumber.Int64())
+
+	// a broken file keeps the current certificate in use
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "server.crt"), []byte("garbage"), 0o600))
+	cert, err = handshake(t, reloader.ServerConfig(false), client)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), cert.SerialNumber.Int64())
+}